@@ -0,0 +1,96 @@
+package downloader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBasicAuth(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	// every request must be authenticated, the first part fails once
+	var mu sync.Mutex
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "alice" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		fail := !failed && r.Method == http.MethodGet
+		if fail {
+			failed = true
+		}
+		mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(original))
+	}))
+	defer server.Close()
+
+	withUserinfo := strings.Replace(server.URL, "http://", "http://alice:secret@", 1)
+	testCases := []struct {
+		Url      string
+		User     string
+		Password string
+	}{
+		{Url: withUserinfo + "/book.pdf"},
+		{Url: server.URL + "/book.pdf", User: "alice", Password: "secret"},
+	}
+
+	for _, testCase := range testCases {
+		mu.Lock()
+		failed = false
+		mu.Unlock()
+
+		outFilename := tempOutFilename(t)
+		defer os.Remove(outFilename)
+
+		logger := &recordingLogger{}
+		d, err := NewFromConfig(&Config{
+			Url:               testCase.Url,
+			Concurrency:       2,
+			MinPartSize:       1,
+			OutFilename:       outFilename,
+			BasicAuthUser:     testCase.User,
+			BasicAuthPassword: testCase.Password,
+			MaxRetries:        1,
+			RetryBackoff:      time.Millisecond,
+			Logger:            logger,
+			LogLevel:          LogLevelVerbose,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+		if err := d.Download(); err != nil {
+			t.Fatal(err)
+		}
+
+		downloaded, err := ioutil.ReadFile(outFilename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(original, downloaded) {
+			t.Error("Downloaded file differs from the original")
+		}
+		if !logger.contains("retrying") {
+			t.Error("Expected the failed part to be logged")
+		}
+		if logger.contains("secret") {
+			t.Error("Expected the password not to be logged")
+		}
+	}
+}
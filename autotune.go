@@ -0,0 +1,144 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// the most connections AutoTune tries when Concurrency is 1
+const defaultAutoTuneConcurrency = 4
+
+// how much faster a level with more connections must be to be picked,
+// so noise in the measurements doesn't open connections for nothing
+const autoTuneMinGain = 1.1
+
+// bytes each connection of a probe fetches, the file must be at
+// least four times the size of the largest probe to be tuned
+var autoTuneProbeSize = 256 * 1024
+
+// Returns the numbers of connections AutoTune measures: 1 and the
+// powers of two up to Concurrency, or defaultAutoTuneConcurrency if
+// Concurrency is 1, limited to the parts which may request at once
+func autoTuneLevels(config *Config) []int {
+	max := config.Concurrency
+	if max < 2 {
+		max = defaultAutoTuneConcurrency
+	}
+	if slots := connSlots(config); slots < max {
+		max = slots
+	}
+
+	levels := []int{1}
+	for n := 2; n < max; n *= 2 {
+		levels = append(levels, n)
+	}
+	if max > 1 {
+		levels = append(levels, max)
+	}
+	return levels
+}
+
+// Returns the most parts which may run at once, the largest
+// level of AutoTune if it's set, otherwise Concurrency
+func maxConcurrency(config *Config) int {
+	if config.AutoTune {
+		levels := autoTuneLevels(config)
+		return levels[len(levels)-1]
+	}
+	return config.Concurrency
+}
+
+// Measures the throughput of the levels of autoTuneLevels, each
+// connection fetching a small range of the file, and sets Concurrency to
+// the fastest. The probed bytes are discarded. Tuning is best effort,
+// Concurrency is kept if a probe fails or the file is too small.
+func (d *Downloader) autoTune(contentSize int) {
+	if !d.config.AutoTune || int64(contentSize) < d.config.ConcurrencyThreshold {
+		return
+	}
+	if d.config.Resume {
+		// a download being resumed keeps its parts
+		if saved, err := d.loadMetadata(); err != nil || saved != nil {
+			return
+		}
+	}
+	levels := autoTuneLevels(d.config)
+	if contentSize < 4*levels[len(levels)-1]*autoTuneProbeSize {
+		d.debugf("File is too small to tune the concurrency level")
+		return
+	}
+
+	best, bestSpeed := 0, 0.0
+	for _, n := range levels {
+		speed, err := d.measureThroughput(contentSize, n)
+		if err != nil {
+			d.debugf("Tuning the concurrency level failed: %v", err)
+			return
+		}
+		d.debugf("%d connections: %.0f KiB/s", n, speed/1024)
+		if best == 0 || speed > bestSpeed*autoTuneMinGain {
+			best, bestSpeed = n, speed
+		}
+	}
+
+	d.config.Concurrency = best
+	d.logf("Tuned concurrency level: %d", best)
+}
+
+// Fetches autoTuneProbeSize bytes with each of n connections at once,
+// from ranges spread over the file, and returns the bytes per second
+func (d *Downloader) measureThroughput(contentSize, n int) (float64, error) {
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rangeStart := contentSize / n * i
+			errs[i] = d.fetchProbe(rangeStart, rangeStart+autoTuneProbeSize-1)
+		}(i)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	for _, err := range errs {
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := d.context.Err(); err != nil {
+		return 0, err
+	}
+	return float64(n*autoTuneProbeSize) / elapsed.Seconds(), nil
+}
+
+// Fetches the given range of the file and discards it
+func (d *Downloader) fetchProbe(rangeStart, rangeStop int) error {
+	ctx, cancel := d.requestContext(d.context)
+	defer cancel()
+	req, err := d.newRequest(ctx, http.MethodGet, d.ResolvedURL())
+	if err != nil {
+		return err
+	}
+	offset := int(d.config.RangeStart)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset+rangeStart, offset+rangeStop))
+	req.Header.Set("Accept-Encoding", "identity")
+
+	res, err := d.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusPartialContent {
+		// a 200 is the whole file, which the download finds out itself
+		return newStatusError(res)
+	}
+
+	_, err = io.Copy(ioutil.Discard, d.limitReader(newStallReader(res.Body, d.config.StallTimeout, cancel)))
+	return err
+}
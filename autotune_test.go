@@ -0,0 +1,109 @@
+package downloader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAutoTuneLevels(t *testing.T) {
+	testCases := []struct {
+		Concurrency     int
+		MaxConnsPerHost int
+		Expected        []int
+	}{
+		{1, 0, []int{1, 2, 4}},
+		{2, 0, []int{1, 2}},
+		{6, 0, []int{1, 2, 4, 6}},
+		{8, 0, []int{1, 2, 4, 8}},
+		{8, 3, []int{1, 2, 3}},
+		{8, 1, []int{1}},
+	}
+
+	for _, testCase := range testCases {
+		levels := autoTuneLevels(&Config{
+			Concurrency:     testCase.Concurrency,
+			MaxConnsPerHost: testCase.MaxConnsPerHost,
+			MaxOpenParts:    defaultMaxOpenParts,
+		})
+		if !reflect.DeepEqual(levels, testCase.Expected) {
+			t.Errorf("Expected levels %v for concurrency %d, got %v", testCase.Expected, testCase.Concurrency, levels)
+		}
+	}
+}
+
+func TestAutoTune(t *testing.T) {
+	defer func(size int) { autoTuneProbeSize = size }(autoTuneProbeSize)
+	autoTuneProbeSize = 32 * 1024
+	data := bytes.Repeat([]byte("0123456789abcdef"), 32*1024)
+
+	testCases := []struct {
+		Name          string
+		SharedLatency bool
+		Expected      int
+	}{
+		// every connection is slowed down, so more are faster
+		{"latency", false, 4},
+		// the connections share a slow link, more aren't faster
+		{"shared link", true, 1},
+	}
+
+	for _, testCase := range testCases {
+		server := newTestServer(t, data, testServerOptions{
+			Latency:       5 * time.Millisecond,
+			SharedLatency: testCase.SharedLatency,
+			ChunkSize:     4 * 1024,
+		})
+		outFilename := tempOutFilename(t)
+		d, err := NewFromConfig(&Config{
+			Url:         server.URL,
+			OutFilename: outFilename,
+			MinPartSize: 1,
+			AutoTune:    true,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+		if err := d.Download(); err != nil {
+			t.Fatal(err)
+		}
+		downloaded, err := ioutil.ReadFile(outFilename)
+		os.Remove(outFilename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, downloaded) {
+			t.Errorf("%s: Downloaded file is not the same as original file", testCase.Name)
+		}
+		if d.config.Concurrency != testCase.Expected {
+			t.Errorf("%s: Expected concurrency level %d, got %d", testCase.Name, testCase.Expected, d.config.Concurrency)
+		}
+	}
+
+	// a file too small to measure keeps its concurrency level
+	server := newTestServer(t, data[:64*1024], testServerOptions{})
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL,
+		OutFilename: outFilename,
+		Concurrency: 2,
+		MinPartSize: 1,
+		AutoTune:    true,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+	if d.config.Concurrency != 2 {
+		t.Errorf("Expected concurrency level 2, got %d", d.config.Concurrency)
+	}
+	if requests := len(server.Requests()); requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests)
+	}
+}
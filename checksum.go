@@ -0,0 +1,76 @@
+package downloader
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Returned when the downloaded file doesn't match its expected checksum
+var ErrChecksumMismatch = errors.New("Checksum mismatch")
+
+// checksum computes a hash while the file is being written
+// and compares it against the expected value
+type checksum struct {
+	algorithm string
+	expected  string
+	hash      hash.Hash
+}
+
+func (c *checksum) verify() error {
+	actual := hex.EncodeToString(c.hash.Sum(nil))
+	if !strings.EqualFold(actual, strings.TrimSpace(c.expected)) {
+		return fmt.Errorf("%w: expected %s %s, got %s", ErrChecksumMismatch, c.algorithm, c.expected, actual)
+	}
+
+	return nil
+}
+
+// Returns a checksum for each expected hash in the config
+// and the one read from the checksum file
+func (d *Downloader) checksums() []*checksum {
+	var sums []*checksum
+	if d.config.ExpectedSHA256 != "" {
+		sums = append(sums, &checksum{algorithm: "sha256", expected: d.config.ExpectedSHA256, hash: sha256.New()})
+	}
+	if d.config.ExpectedMD5 != "" {
+		sums = append(sums, &checksum{algorithm: "md5", expected: d.config.ExpectedMD5, hash: md5.New()})
+	}
+	if c := d.urlChecksum; c != nil {
+		sums = append(sums, &checksum{algorithm: c.algorithm, expected: c.expected, hash: newHash(c.algorithm)})
+	}
+
+	return sums
+}
+
+func newHash(algorithm string) hash.Hash {
+	if algorithm == "md5" {
+		return md5.New()
+	}
+	return sha256.New()
+}
+
+// Returns a writer which feeds both w and all the checksums
+func checksumWriter(w io.Writer, sums []*checksum) io.Writer {
+	writers := []io.Writer{w}
+	for _, sum := range sums {
+		writers = append(writers, sum.hash)
+	}
+
+	return io.MultiWriter(writers...)
+}
+
+func verifyChecksums(sums []*checksum) error {
+	for _, sum := range sums {
+		if err := sum.verify(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package downloader
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChecksumVerification(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	sum := sha256.Sum256(original)
+	validSum := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	testCases := []struct {
+		Concurrency int
+		SHA256      string
+		Valid       bool
+	}{
+		{Concurrency: 1, SHA256: validSum, Valid: true},
+		{Concurrency: 4, SHA256: validSum, Valid: true},
+		{Concurrency: 4, SHA256: strings.Repeat("0", 64), Valid: false},
+	}
+
+	for _, testCase := range testCases {
+		outFilename := tempOutFilename(t)
+
+		d, err := NewFromConfig(&Config{
+			Url:            server.URL + "/book.pdf",
+			Concurrency:    testCase.Concurrency,
+			OutFilename:    outFilename,
+			ExpectedSHA256: testCase.SHA256,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+
+		err = d.Download()
+		if testCase.Valid && err != nil {
+			t.Errorf("Expected checksum to match, got %v", err)
+		}
+		if !testCase.Valid && err == nil {
+			t.Error("Expected checksum mismatch error")
+		}
+
+		os.Remove(outFilename)
+	}
+}
+
+func TestParseChecksumFile(t *testing.T) {
+	sha := strings.Repeat("ab", 32)
+	md := strings.Repeat("cd", 16)
+
+	testCases := []struct {
+		Content   string
+		Algorithm string
+		Expected  string
+		Valid     bool
+	}{
+		{Content: sha + "  file.zip\n", Algorithm: "sha256", Expected: sha, Valid: true},
+		{Content: md + " *file.zip", Algorithm: "md5", Expected: md, Valid: true},
+		// just the hash
+		{Content: sha + "\n", Algorithm: "sha256", Expected: sha, Valid: true},
+		// a single line names the file differently
+		{Content: sha + "  ./dist/file-1.0.zip\n", Algorithm: "sha256", Expected: sha, Valid: true},
+		{Content: md + "  other.zip\n" + sha + "  ./file.zip\n", Algorithm: "sha256", Expected: sha, Valid: true},
+		{Content: md + "  other.zip\n" + sha + "  another.zip\n", Valid: false},
+		{Content: "", Valid: false},
+		{Content: "not-a-hash  file.zip", Valid: false},
+		{Content: strings.Repeat("ab", 20) + "  file.zip", Valid: false},
+	}
+
+	for _, testCase := range testCases {
+		sum, err := parseChecksumFile(testCase.Content, []string{"file.zip"})
+		if !testCase.Valid {
+			if err == nil {
+				t.Errorf("Expected %q to be invalid", testCase.Content)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected %q to be parsed, got %v", testCase.Content, err)
+			continue
+		}
+		if sum.algorithm != testCase.Algorithm || sum.expected != testCase.Expected {
+			t.Errorf("Expected %s %s, got %s %s", testCase.Algorithm, testCase.Expected, sum.algorithm, sum.expected)
+		}
+	}
+}
+
+func TestChecksumURL(t *testing.T) {
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	sha := sha256.Sum256(data)
+	md := md5.Sum(data)
+	checksumFiles := map[string]string{
+		"/good.zip.sha256": hex.EncodeToString(sha[:]) + "  good.zip\n",
+		"/bad.zip.sha256":  strings.Repeat("0", 64) + "  bad.zip\n",
+		"/other.zip.md5":   hex.EncodeToString(md[:]) + "  other.zip\n",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if content, ok := checksumFiles[r.URL.Path]; ok {
+			w.Write([]byte(content))
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".sha256") || strings.HasSuffix(r.URL.Path, ".md5") {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		Path        string
+		ChecksumURL string
+		Probe       bool
+		Valid       bool
+	}{
+		{Path: "/good.zip", ChecksumURL: server.URL + "/good.zip.sha256", Valid: true},
+		{Path: "/bad.zip", ChecksumURL: server.URL + "/bad.zip.sha256", Valid: false},
+		// the checksum file doesn't exist
+		{Path: "/good.zip", ChecksumURL: server.URL + "/missing.sha256", Valid: false},
+		{Path: "/good.zip", Probe: true, Valid: true},
+		{Path: "/bad.zip", Probe: true, Valid: false},
+		// falls back to the md5 file
+		{Path: "/other.zip", Probe: true, Valid: true},
+		// no checksum file, nothing to verify
+		{Path: "/unknown.zip", Probe: true, Valid: true},
+	}
+
+	for _, testCase := range testCases {
+		outFilename := tempOutFilename(t)
+
+		d, err := NewFromConfig(&Config{
+			Url:              server.URL + testCase.Path,
+			Concurrency:      2,
+			MinPartSize:      1,
+			OutFilename:      outFilename,
+			ChecksumURL:      testCase.ChecksumURL,
+			ProbeChecksumURL: testCase.Probe,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+
+		err = d.Download()
+		if testCase.Valid && err != nil {
+			t.Errorf("Expected %s to be verified, got %v", testCase.Path, err)
+		}
+		if !testCase.Valid && err == nil {
+			t.Errorf("Expected %s to fail verification", testCase.Path)
+		}
+
+		os.Remove(outFilename)
+	}
+}
@@ -0,0 +1,138 @@
+package downloader
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// checksum files list a few hashes, anything larger isn't one
+const maxChecksumFileSize = 64 * 1024
+
+// lengths of the hex encoded hashes, which tell their algorithm
+const (
+	sha256HexLen = 64
+	md5HexLen    = 32
+)
+
+// Fetches the checksum file at ChecksumURL, or probes for one next to
+// the file with ProbeChecksumURL, and keeps its hash for the file to be
+// verified against. A probed file which doesn't exist is not an error.
+func (d *Downloader) fetchChecksum() error {
+	if d.urlChecksum != nil {
+		// fetched before the download was paused
+		return nil
+	}
+	if d.config.ChecksumURL != "" {
+		sum, err := d.readChecksumFile(d.config.ChecksumURL)
+		if err != nil {
+			return fmt.Errorf("Cannot read checksum file: %w", err)
+		}
+		d.urlChecksum = sum
+		return nil
+	}
+	if !d.config.ProbeChecksumURL || isFTP(d.config.Url) {
+		return nil
+	}
+
+	for _, ext := range []string{".sha256", ".md5"} {
+		sum, err := d.readChecksumFile(d.config.Url + ext)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot read checksum file: %w", err)
+		}
+		d.logf("Verifying the file against %s", redactURL(d.config.Url+ext))
+		d.urlChecksum = sum
+		return nil
+	}
+
+	d.logf("No checksum file found, the file is not verified")
+	return nil
+}
+
+// Downloads the checksum file at url and returns the hash of the file
+func (d *Downloader) readChecksumFile(url string) (*checksum, error) {
+	ctx, cancel := d.requestContext(d.context)
+	defer cancel()
+	req, err := d.newRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := d.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, newStatusError(res)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxChecksumFileSize))
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{detectFilename(d.config.Url), filepath.Base(d.config.OutFilename)}
+	if d.resolvedURL != "" {
+		names = append(names, detectFilename(d.resolvedURL))
+	}
+	return parseChecksumFile(string(data), names)
+}
+
+// Parses a checksum file in the format of sha256sum and md5sum, a line
+// of "<hash>  <filename>" for each file, and returns the hash of the
+// file with one of the given names. A file of a single line may have
+// another filename, or just the hash. The algorithm is told by the
+// length of the hash.
+func parseChecksumFile(content string, names []string) (*checksum, error) {
+	var lines [][]string
+	for _, line := range strings.Split(content, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && !strings.HasPrefix(fields[0], "#") {
+			lines = append(lines, fields)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("checksum file is empty")
+	}
+
+	var sum string
+	if len(lines) == 1 {
+		sum = lines[0][0]
+	}
+	for _, fields := range lines {
+		if len(fields) < 2 {
+			continue
+		}
+		// '*' marks a file hashed in binary mode
+		filename := path.Base(strings.TrimPrefix(strings.Join(fields[1:], " "), "*"))
+		for _, name := range names {
+			if filename == name {
+				sum = fields[0]
+			}
+		}
+	}
+	if sum == "" {
+		return nil, fmt.Errorf("checksum file has no hash of %s", names[0])
+	}
+
+	if _, err := hex.DecodeString(sum); err != nil {
+		return nil, fmt.Errorf("invalid hash %q in checksum file", sum)
+	}
+	switch len(sum) {
+	case sha256HexLen:
+		return &checksum{algorithm: "sha256", expected: sum}, nil
+	case md5HexLen:
+		return &checksum{algorithm: "md5", expected: sum}, nil
+	}
+	return nil, fmt.Errorf("unsupported hash %q in checksum file, only SHA-256 and MD5 are supported", sum)
+}
@@ -0,0 +1,108 @@
+package downloader
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Removes the part files, the incomplete file they're merged into and the
+// metadata file left by a failed or abandoned download. The download can't be resumed
+// afterwards. It must not be called while the download is running.
+func (d *Downloader) Cleanup() error {
+	if d.State() == StateDownloading {
+		return errors.New("Cannot clean up while downloading")
+	}
+
+	return d.removeLeftovers()
+}
+
+// Removes the files left by downloads of the output file. Other storages
+// can't be listed, only the files of this download's url are removed.
+func (d *Downloader) removeLeftovers() error {
+	if err := d.storage.Remove(d.incompleteFilename()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if d.isLocalStorage() {
+		return CleanupOrphans(d.partsDir(), filepath.Base(d.config.OutFilename))
+	}
+	return d.removePartFiles()
+}
+
+// Removes the part files, the merged file and the metadata file of this
+// download's url, the part files of other urls to the same file are kept
+func (d *Downloader) removePartFiles() error {
+	parts := d.config.Concurrency
+	if meta, err := d.loadMetadata(); err == nil && meta != nil && len(meta.Parts) > parts {
+		parts = len(meta.Parts)
+	}
+	names := []string{d.metadataFilename(), d.mergedFilename(), d.partsPrefix() + ".tmp"}
+	for i := 1; i <= parts; i++ {
+		names = append(names, d.getPartFilename(i))
+	}
+
+	for _, name := range names {
+		if err := d.storage.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Removes the files left in dir by downloads of the file named basename,
+// i.e. its part files, metadata file and incomplete file, whatever their
+// url. Only an incomplete file with the default IncompleteSuffix is known.
+func CleanupOrphans(dir, basename string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !isOrphan(file.Name(), basename) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, file.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Whether name is one of the files created while downloading basename,
+// from any url. The files of older versions have no url hash, and they
+// merged the parts into a .tmp file.
+func isOrphan(name, basename string) bool {
+	suffix := strings.TrimPrefix(name, basename)
+	if suffix == name {
+		return false
+	}
+	if suffix == defaultIncompleteSuffix {
+		// the parts are merged into it, it has no url hash
+		return true
+	}
+	if hash := strings.SplitN(strings.TrimPrefix(suffix, "."), ".", 2)[0]; isURLHash(hash) {
+		suffix = strings.TrimPrefix(suffix, "."+hash)
+	}
+	if suffix == ".tmp" || suffix == ".godl.json" {
+		return true
+	}
+	if !strings.HasPrefix(suffix, ".part") {
+		return false
+	}
+	_, err := strconv.Atoi(strings.TrimPrefix(suffix, ".part"))
+	return err == nil
+}
+
+func isURLHash(s string) bool {
+	if len(s) != urlHashLen {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,85 @@
+package downloader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go_dl_cleanup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hash := urlHash("http://localhost/book.pdf")
+	leftovers := []string{
+		"book.pdf.part1", "book.pdf.part12", "book.pdf.tmp", "book.pdf.godl.json",
+		"book.pdf." + hash + ".part1", "book.pdf." + hash + ".tmp", "book.pdf." + hash + ".godl.json",
+		// of another url
+		"book.pdf.0123abcd.part2",
+	}
+	kept := []string{"book.pdf", "book.pdf.partial", "other.pdf.part1", "book.pdf.part1.bak", "book.pdf.v2.part1"}
+	for _, name := range append(leftovers, kept...) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := NewFromConfig(&Config{
+		Url:         "http://localhost/book.pdf",
+		OutFilename: filepath.Join(dir, "book.pdf"),
+		OnExist:     OnExistOverwrite,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range leftovers {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", name)
+		}
+	}
+	for _, name := range kept {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", name, err)
+		}
+	}
+}
+
+func TestCleanupOrphans(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go_dl_cleanup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hash := urlHash("http://localhost/book.pdf")
+	leftovers := []string{"book.pdf.download", "book.pdf." + hash + ".part1", "book.pdf." + hash + ".godl.json"}
+	kept := []string{"book.pdf", "book.pdf.crdownload", "other.pdf.download", "book.pdf." + hash + ".download"}
+	for _, name := range append(leftovers, kept...) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := CleanupOrphans(dir, "book.pdf"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range leftovers {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", name)
+		}
+	}
+	for _, name := range kept {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", name, err)
+		}
+	}
+}
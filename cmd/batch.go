@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	downloader "github.com/mostafa-asg/go-dl"
+)
+
+// a line of the url list
+type batchEntry struct {
+	url string
+	// empty if it's detected from the url
+	filename string
+}
+
+// Reads a url per line, optionally followed by a tab and the filename.
+// Empty lines and the ones starting with # are skipped.
+func readURLList(r io.Reader) ([]batchEntry, error) {
+	var entries []batchEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry := batchEntry{url: line}
+		if index := strings.Index(line, "\t"); index != -1 {
+			entry.url = strings.TrimSpace(line[:index])
+			entry.filename = strings.TrimSpace(line[index+1:])
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// Opens the url list, - is stdin
+func openURLList(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(name)
+}
+
+// Returns a copy of config for every url of the list
+func batchConfigs(config *downloader.Config, entries []batchEntry) []*downloader.Config {
+	configs := make([]*downloader.Config, len(entries))
+	for i, entry := range entries {
+		c := *config
+		c.Url = entry.url
+		c.Filename = entry.filename
+		configs[i] = &c
+	}
+	return configs
+}
+
+// Downloads the files, at most maxDownloads at a time, and prints
+// which ones have failed. totalLimit is their total speed in bytes per
+// second, unlimited if zero. Returns false if any of them has failed.
+func runBatch(configs []*downloader.Config, maxDownloads int, totalLimit int64) bool {
+	m := downloader.NewManager(maxDownloads)
+	m.MaxBytesPerSecond = totalLimit
+	for _, c := range configs {
+		m.Add(c)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	termCh := make(chan os.Signal, 1)
+	signal.Notify(termCh, os.Interrupt)
+	go func() {
+		<-termCh
+		println("\nExiting ...")
+		cancel()
+	}()
+
+	m.Run(ctx)
+
+	failed := 0
+	for _, result := range m.Results() {
+		switch {
+		case errors.Is(result.Err, downloader.ErrChecksumMismatch):
+			failed++
+			fmt.Fprintf(os.Stderr, "Verification failed: %s: %v\n", result.Config.Url, result.Err)
+		case result.Err != nil:
+			failed++
+			fmt.Fprintf(os.Stderr, "Failed: %s: %v\n", result.Config.Url, result.Err)
+		}
+	}
+	fmt.Printf("%d of %d downloads completed, %d failed.\n", len(configs)-failed, len(configs), failed)
+
+	return failed == 0
+}
@@ -3,3 +3,5 @@ module github.com/mostafa-asg/go-dl/cmd
 go 1.14
 
 require github.com/mostafa-asg/go-dl v1.0.0
+
+replace github.com/mostafa-asg/go-dl => ../
@@ -0,0 +1,47 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// Returned when the disk doesn't have enough free space for the download
+var ErrInsufficientDiskSpace = errors.New("Insufficient disk space")
+
+// Makes sure the disks have room for the download. Finished parts are
+// appended to the merged file and removed one by one, so besides the
+// file size the parts directory needs room for one extra part.
+// parts is zero for a single stream download.
+func (d *Downloader) checkDiskSpace(contentSize int64, parts int) error {
+	if d.config.SkipDiskCheck || contentSize <= 0 || !d.isLocalStorage() {
+		return nil
+	}
+
+	outputDir := filepath.Dir(d.config.OutFilename)
+	if parts == 0 {
+		return ensureFreeSpace(outputDir, contentSize)
+	}
+
+	partSize := contentSize/int64(parts) + 1
+	if err := ensureFreeSpace(d.partsDir(), contentSize+partSize); err != nil {
+		return err
+	}
+	if d.partsDir() != outputDir {
+		return ensureFreeSpace(outputDir, contentSize)
+	}
+	return nil
+}
+
+func ensureFreeSpace(dir string, required int64) error {
+	free, err := freeDiskSpace(dir)
+	if err != nil || free < 0 {
+		// can't tell, let the download try
+		return nil
+	}
+
+	if free < required {
+		return fmt.Errorf("%w: %s has %d bytes free, %d bytes required", ErrInsufficientDiskSpace, dir, free, required)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package downloader
+
+// The free space is unknown on this platform
+func freeDiskSpace(dir string) (int64, error) {
+	return -1, nil
+}
@@ -0,0 +1,24 @@
+package downloader
+
+import (
+	"errors"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestEnsureFreeSpace(t *testing.T) {
+	free, err := freeDiskSpace(os.TempDir())
+	if err != nil || free < 0 {
+		t.Skip("Free disk space is not supported on this platform")
+	}
+
+	if err := ensureFreeSpace(os.TempDir(), 1); err != nil {
+		t.Errorf("Expected 1 byte to fit, got %v", err)
+	}
+
+	err = ensureFreeSpace(os.TempDir(), math.MaxInt64)
+	if !errors.Is(err, ErrInsufficientDiskSpace) {
+		t.Errorf("Expected ErrInsufficientDiskSpace, got %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package downloader
+
+import "golang.org/x/sys/unix"
+
+// Returns the bytes available to the user on the volume of dir
+func freeDiskSpace(dir string) (int64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(dir, &stat); err != nil {
+		return -1, err
+	}
+
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package downloader
+
+import "golang.org/x/sys/windows"
+
+// Returns the bytes available to the user on the volume of dir
+func freeDiskSpace(dir string) (int64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return -1, err
+	}
+
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &free, &total, &totalFree); err != nil {
+		return -1, err
+	}
+
+	return int64(free), nil
+}
@@ -0,0 +1,36 @@
+package downloader
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Returns the Accept-Encoding sent with the single stream request.
+// Ranges are always requested with identity encoding, so their
+// offsets are offsets in the file rather than in a compressed stream.
+func (d *Downloader) acceptEncoding() string {
+	if d.config.Decompress {
+		return "gzip, deflate"
+	}
+
+	return "identity"
+}
+
+// Wraps body with a decompressor matching the response's Content-Encoding
+func decodeBody(res *http.Response, body io.Reader) (io.Reader, error) {
+	switch encoding := strings.ToLower(res.Header.Get("Content-Encoding")); encoding {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		// HTTP's deflate is actually zlib wrapped
+		return zlib.NewReader(body)
+	default:
+		return nil, fmt.Errorf("Unsupported Content-Encoding: %s", encoding)
+	}
+}
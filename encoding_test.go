@@ -0,0 +1,76 @@
+package downloader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(original)
+	gz.Close()
+
+	// compresses the file if the client accepts it, doesn't support ranges
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := original
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			body = compressed.Bytes()
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		Decompress    bool
+		ExpectedTotal int64
+	}{
+		{Decompress: false, ExpectedTotal: int64(len(original))},
+		{Decompress: true, ExpectedTotal: int64(compressed.Len())},
+	}
+
+	for _, testCase := range testCases {
+		outFilename := tempOutFilename(t)
+		defer os.Remove(outFilename)
+
+		d, err := NewFromConfig(&Config{
+			Url:         server.URL + "/book.pdf",
+			OutFilename: outFilename,
+			Decompress:  testCase.Decompress,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+		if err := d.Download(); err != nil {
+			t.Fatal(err)
+		}
+
+		downloaded, err := ioutil.ReadFile(outFilename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(original, downloaded) {
+			t.Errorf("Decompress %v: downloaded file is not the same as the original", testCase.Decompress)
+		}
+		stats := d.Stats()
+		if stats.Downloaded != testCase.ExpectedTotal || stats.Total != testCase.ExpectedTotal {
+			t.Errorf("Decompress %v: expected progress %d/%d, got %d/%d", testCase.Decompress,
+				testCase.ExpectedTotal, testCase.ExpectedTotal, stats.Downloaded, stats.Total)
+		}
+	}
+}
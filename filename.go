@@ -0,0 +1,63 @@
+package downloader
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maximum length of a sanitized filename in bytes. Most filesystems allow
+// 255, the rest is left for the suffixes of the part files and renaming.
+const maxFilenameLen = 200
+
+// names Windows reserves for devices, with or without an extension
+var reservedFilenames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFilename makes name safe to save a file with on any OS. The
+// characters Windows doesn't allow (<>:"/\|?* and control characters)
+// are replaced with '_', trailing dots and spaces are removed, reserved
+// device names like CON get a '_' prefix, and a long name is truncated
+// to 200 bytes keeping its extension. It returns "download" for a name
+// with nothing left, e.g. "" or "..".
+func SanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(strings.TrimRight(name, ". "))
+	if name == "" {
+		return defaultFilename
+	}
+
+	base, ext := getFilenameAndExt(name)
+	if reservedFilenames[strings.ToUpper(base)] {
+		base = "_" + base
+	}
+	if len(base)+len(ext) > maxFilenameLen {
+		// an extension that long is more likely a part of the name
+		if len(ext) > maxFilenameLen/4 {
+			base, ext = base+ext, ""
+		}
+		base = truncateUTF8(base, maxFilenameLen-len(ext))
+	}
+
+	return base + ext
+}
+
+// Cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,47 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Expected string
+	}{
+		{Name: "book.pdf", Expected: "book.pdf"},
+		{Name: "my file.zip", Expected: "my file.zip"},
+		{Name: `re: "draft"?.txt`, Expected: "re_ _draft__.txt"},
+		{Name: `a<b>c|d*e\f/g.bin`, Expected: "a_b_c_d_e_f_g.bin"},
+		{Name: "tab\there\n.txt", Expected: "tab_here_.txt"},
+		{Name: "ends with dots...", Expected: "ends with dots"},
+		{Name: "  spaced.txt  ", Expected: "spaced.txt"},
+		{Name: "CON", Expected: "_CON"},
+		{Name: "nul.txt", Expected: "_nul.txt"},
+		{Name: "console.txt", Expected: "console.txt"},
+		{Name: "", Expected: "download"},
+		{Name: "..", Expected: "download"},
+		{Name: strings.Repeat("a", 300) + ".tar", Expected: strings.Repeat("a", 196) + ".tar"},
+		{Name: strings.Repeat("b", 150) + "." + strings.Repeat("c", 150), Expected: strings.Repeat("b", 150) + "." + strings.Repeat("c", 49)},
+	}
+
+	for _, testCase := range testCases {
+		actual := SanitizeFilename(testCase.Name)
+		if actual != testCase.Expected {
+			t.Errorf("Expected %q to be sanitized to %q, got %q", testCase.Name, testCase.Expected, actual)
+		}
+	}
+}
+
+func TestSanitizeFilenameKeepsCharacters(t *testing.T) {
+	// 3 bytes per character, 200 isn't a multiple of it
+	name := SanitizeFilename(strings.Repeat("文", 100) + ".txt")
+	if !utf8.ValidString(name) {
+		t.Errorf("Expected a valid UTF-8 name, got %q", name)
+	}
+	if len(name) > maxFilenameLen || !strings.HasSuffix(name, ".txt") {
+		t.Errorf("Expected at most %d bytes ending with .txt, got %d bytes: %q", maxFilenameLen, len(name), name)
+	}
+}
@@ -0,0 +1,178 @@
+package downloader
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// Whether the url is downloaded over FTP rather than HTTP
+func isFTP(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "ftp" || u.Scheme == "ftps")
+}
+
+// Connects and logs in to the server of the FTP url. The credentials
+// come from the url, the login is anonymous without them. ftps urls
+// use implicit TLS.
+func (d *Downloader) dialFTP(u *url.URL) (*ftp.ServerConn, error) {
+	port := u.Port()
+	if port == "" {
+		port = "21"
+		if u.Scheme == "ftps" {
+			port = "990"
+		}
+	}
+
+	options := []ftp.DialOption{ftp.DialWithContext(d.context)}
+	if d.config.RequestTimeout > 0 {
+		options = append(options, ftp.DialWithTimeout(d.config.RequestTimeout))
+	}
+	if u.Scheme == "ftps" {
+		tlsConfig := &tls.Config{}
+		if d.config.TLSConfig != nil {
+			tlsConfig = d.config.TLSConfig.Clone()
+		}
+		tlsConfig.ServerName = u.Hostname()
+		options = append(options, ftp.DialWithTLS(tlsConfig))
+	}
+	conn, err := ftp.Dial(net.JoinHostPort(u.Hostname(), port), options...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the credentials of the url, BasicAuthUser or the netrc file
+	user, password := "anonymous", "anonymous"
+	if d.basicAuth != nil {
+		user = d.basicAuth.Username()
+		password, _ = d.basicAuth.Password()
+	}
+	if err := conn.Login(user, password); err != nil {
+		conn.Quit()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// Downloads the file from an FTP server in a single stream. The size
+// comes from the SIZE command and resume continues from the size of
+// the output file using REST.
+func (d *Downloader) ftpDownload() error {
+	if d.rangeRequested() {
+		return ErrRangesUnsupported
+	}
+	u, err := url.Parse(d.config.Url)
+	if err != nil {
+		return err
+	}
+	conn, err := d.dialFTP(u)
+	if err != nil {
+		return err
+	}
+	defer conn.Quit()
+
+	// SIZE is an extension, the size is unknown without it
+	d.remoteSize, err = conn.FileSize(u.Path)
+	if err != nil {
+		d.remoteSize = -1
+	}
+	if err := d.checkMaxSize(d.remoteSize); err != nil {
+		return err
+	}
+
+	skip, err := d.checkOutputExists()
+	if err != nil || skip {
+		return err
+	}
+	if err := d.prepareOutputDir(); err != nil {
+		return err
+	}
+	if err := d.fetchChecksum(); err != nil {
+		return err
+	}
+
+	var f WriteSeekCloser
+	var offset int64
+	if d.config.Resume {
+		f, offset, err = d.openIncomplete()
+	} else {
+		f, err = d.createTruncated(d.incompleteFilename())
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if d.remoteSize >= 0 && offset > d.remoteSize {
+		return fmt.Errorf("Cannot resume, %s is larger than the remote file", d.incompleteFilename())
+	}
+	if d.remoteSize >= 0 {
+		if err := d.checkDiskSpace(d.remoteSize-offset, 0); err != nil {
+			return err
+		}
+	}
+
+	// hash what is already downloaded
+	sums := d.checksums()
+	if err := d.hashFile(d.incompleteFilename(), offset, sums); err != nil {
+		return err
+	}
+
+	d.startProgress(d.remoteSize)
+	d.progress.addExisting(offset)
+	if offset == d.remoteSize {
+		return d.complete(d.finishIncomplete(f, verifyChecksums(sums)))
+	}
+	if offset > 0 {
+		d.logf("Resuming from %d bytes", offset)
+	}
+
+	res, err := conn.RetrFrom(u.Path, uint64(offset))
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	// reads from the data connection don't take a context,
+	// so they are interrupted by a deadline
+	ctx, cancel := d.requestContext(d.context)
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			res.SetDeadline(time.Now())
+			conn.Quit()
+		case <-done:
+		}
+	}()
+
+	body := d.limitReader(newStallReader(res, d.config.StallTimeout, cancel))
+	err = d.copyStream(checksumWriter(f, sums), d.limitSize(NewProgressReader(d.context, body, d.countProgress), offset))
+	if syncErr := syncFile(f); err == nil {
+		err = syncErr
+	}
+	if err != nil || d.context.Err() != nil {
+		return err
+	}
+	// the data connection may be closed before the end of the file,
+	// the incomplete file is kept to be resumed
+	if d.remoteSize >= 0 {
+		fileInfo, err := d.storage.Stat(d.incompleteFilename())
+		if err != nil {
+			return err
+		}
+		if fileInfo.Size() != d.remoteSize {
+			return fmt.Errorf("%w: received %d bytes of %d", io.ErrUnexpectedEOF, fileInfo.Size(), d.remoteSize)
+		}
+	}
+
+	return d.complete(d.finishIncomplete(f, verifyChecksums(sums)))
+}
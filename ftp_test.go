@@ -0,0 +1,191 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// serves data as any file over FTP, supporting just
+// what the downloader needs: SIZE, EPSV, REST and RETR
+func serveFTP(t *testing.T, data []byte) net.Listener {
+	return serveTruncatedFTP(t, data, len(data))
+}
+
+// like serveFTP, but a transfer closes its data connection
+// once the file is sent up to the given size
+func serveTruncatedFTP(t *testing.T, data []byte, size int) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handleFTP(conn, data, size)
+		}
+	}()
+	return listener
+}
+
+func handleFTP(conn net.Conn, data []byte, size int) {
+	defer conn.Close()
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	reply("220 ready")
+	var dataListener net.Listener
+	offset := 0
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		switch strings.ToUpper(fields[0]) {
+		case "USER":
+			reply("331 password required")
+		case "PASS":
+			reply("230 logged in")
+		case "TYPE":
+			reply("200 binary")
+		case "SIZE":
+			reply("213 %d", len(data))
+		case "EPSV":
+			dataListener, _ = net.Listen("tcp", "127.0.0.1:0")
+			reply("229 Entering Extended Passive Mode (|||%d|)", dataListener.Addr().(*net.TCPAddr).Port)
+		case "REST":
+			offset, _ = strconv.Atoi(fields[1])
+			reply("350 restarting")
+		case "RETR":
+			reply("150 sending")
+			dataConn, err := dataListener.Accept()
+			dataListener.Close()
+			if err != nil {
+				return
+			}
+			if offset < size {
+				dataConn.Write(data[offset:size])
+			}
+			dataConn.Close()
+			reply("226 done")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestFTPDownload(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	listener := serveFTP(t, original)
+	defer listener.Close()
+
+	testCases := []struct {
+		Existing []byte
+		Resume   bool
+	}{
+		{Existing: nil, Resume: false},
+		{Existing: original[:1000], Resume: true},
+	}
+
+	for _, testCase := range testCases {
+		outFilename := tempOutFilename(t)
+		defer os.Remove(outFilename)
+		if testCase.Existing != nil {
+			if err := ioutil.WriteFile(outFilename, testCase.Existing, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		d, err := NewFromConfig(&Config{
+			Url:         "ftp://" + listener.Addr().String() + "/files/book.pdf",
+			OutFilename: outFilename,
+			OnExist:     OnExistOverwrite,
+			Resume:      testCase.Resume,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+		if err := d.Download(); err != nil {
+			t.Fatal(err)
+		}
+
+		downloaded, err := ioutil.ReadFile(outFilename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(original, downloaded) {
+			t.Errorf("Resume %v: downloaded file is not the same as the original", testCase.Resume)
+		}
+		if stats := d.Stats(); stats.Total != int64(len(original)) {
+			t.Errorf("Expected the size to be %d, got %d", len(original), stats.Total)
+		}
+	}
+}
+
+func TestFTPDownloadTruncated(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	truncated := serveTruncatedFTP(t, original, len(original)/2)
+	defer truncated.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+	defer os.Remove(outFilename + defaultIncompleteSuffix)
+	config := Config{
+		Url:         "ftp://" + truncated.Addr().String() + "/files/book.pdf",
+		OutFilename: outFilename,
+	}
+	d, err := NewFromConfig(&config)
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if _, err := os.Stat(outFilename); !os.IsNotExist(err) {
+		t.Error("Expected no output file after a truncated transfer")
+	}
+	info, err := os.Stat(outFilename + defaultIncompleteSuffix)
+	if err != nil || info.Size() != int64(len(original)/2) {
+		t.Fatalf("Expected the incomplete file to be kept with %d bytes, got %v, %v", len(original)/2, info, err)
+	}
+
+	// the received bytes are continued
+	listener := serveFTP(t, original)
+	defer listener.Close()
+	config.Url = "ftp://" + listener.Addr().String() + "/files/book.pdf"
+	config.Resume = true
+	d, err = NewFromConfig(&config)
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+	downloaded, err := ioutil.ReadFile(outFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, downloaded) {
+		t.Error("Downloaded file is not the same as the original")
+	}
+}
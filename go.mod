@@ -2,4 +2,9 @@ module github.com/mostafa-asg/go-dl
 
 go 1.14
 
-require github.com/schollz/progressbar/v3 v3.7.6
+require (
+	github.com/jlaffaye/ftp v0.0.0-20210307004419-5d4190119067
+	github.com/schollz/progressbar/v3 v3.7.6
+	golang.org/x/sys v0.0.0-20210223095934-7937bea0104d
+	golang.org/x/time v0.0.0-20210723032227-1f47c861a9ac
+)
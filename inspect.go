@@ -0,0 +1,189 @@
+package downloader
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RemoteInfo describes the remote file, as reported by a HEAD request,
+// or by a request for its first byte if the HEAD fails
+type RemoteInfo struct {
+	// the url after following redirects
+	URL string
+	// -1 if the server didn't send Content-Length
+	Size int64
+	// true if the server supports range requests, so
+	// the file can be downloaded concurrently and resumed
+	AcceptRanges bool
+	ContentType  string
+	// from Content-Disposition if present, otherwise from the url
+	Filename     string
+	ETag         string
+	LastModified string
+
+	statusCode int
+	status     string
+}
+
+// Concurrent reports whether the file can be downloaded in parts, which
+// needs range support and the size. Otherwise it's downloaded in a single
+// stream whatever the Concurrency is.
+func (info *RemoteInfo) Concurrent() bool {
+	return info.AcceptRanges && info.Size > 0
+}
+
+// Inspect requests the remote file's metadata without downloading it
+func (d *Downloader) Inspect(ctx context.Context) (*RemoteInfo, error) {
+	info, err := d.head(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if info.statusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: info.statusCode, Status: info.status}
+	}
+
+	return info, nil
+}
+
+// Sends a HEAD request, following redirects, and parses the response.
+// If the HEAD fails or is refused, e.g. with 405 Method Not Allowed, or
+// with NoHead, the first byte of the file is requested instead.
+func (d *Downloader) head(ctx context.Context) (*RemoteInfo, error) {
+	if !d.config.NoHead {
+		info, err := d.requestInfo(ctx, http.MethodHead)
+		if err == nil && info.statusCode == http.StatusOK {
+			return info, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if err == nil {
+			err = &StatusError{StatusCode: info.statusCode, Status: info.status}
+		}
+		d.debugf("HEAD request failed: %v, requesting the first byte instead", err)
+	}
+
+	return d.requestInfo(ctx, http.MethodGet)
+}
+
+// Requests the file's metadata with method, a GET only asks for the first
+// byte, and parses the response
+func (d *Downloader) requestInfo(ctx context.Context, method string) (*RemoteInfo, error) {
+	ctx, cancel := d.requestContext(ctx)
+	defer cancel()
+	req, err := d.newRequest(ctx, method, d.config.Url)
+	if err != nil {
+		return nil, err
+	}
+	// the size of the file itself, not of a compressed response
+	req.Header.Set("Accept-Encoding", "identity")
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	res, err := d.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	info := &RemoteInfo{
+		// the response belongs to the last request of the redirects
+		URL:          res.Request.URL.String(),
+		Size:         -1,
+		AcceptRanges: res.StatusCode == http.StatusOK && res.Header.Get("Accept-Ranges") == "bytes",
+		ContentType:  res.Header.Get("Content-Type"),
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+		statusCode:   res.StatusCode,
+		status:       res.Status,
+	}
+	if size, err := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64); err == nil {
+		info.Size = size
+	}
+	if method == http.MethodGet {
+		parseRangeProbe(info, res)
+	}
+
+	info.Filename = dispositionFilename(res.Header.Get("Content-Disposition"))
+	if info.Filename == "" {
+		info.Filename = detectFilename(info.URL)
+	}
+
+	return info, nil
+}
+
+// Fills info from the response to a request for the first byte. The
+// size is in its Content-Range, unless the server ignored the range and
+// sent the whole file. An empty file can't satisfy the range.
+func parseRangeProbe(info *RemoteInfo, res *http.Response) {
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		info.AcceptRanges = true
+		info.statusCode, info.status = http.StatusOK, "200 OK"
+		// the Content-Length is the length of the range
+		info.Size = -1
+		if cr, err := parseContentRange(res.Header.Get("Content-Range")); err == nil {
+			info.Size = cr.Total
+		}
+	case http.StatusOK:
+		info.AcceptRanges = false
+	case http.StatusRequestedRangeNotSatisfiable:
+		if cr, err := parseContentRange(res.Header.Get("Content-Range")); err == nil && cr.Total == 0 {
+			info.statusCode, info.status = http.StatusOK, "200 OK"
+			info.Size = 0
+		}
+	}
+}
+
+// Returns the filename of a Content-Disposition header, or empty string
+func dispositionFilename(disposition string) string {
+	if disposition == "" {
+		return ""
+	}
+
+	_, params, err := mime.ParseMediaType(disposition)
+	if err != nil || params["filename"] == "" {
+		return ""
+	}
+	// never let the server choose a path
+	name := filepath.Base(params["filename"])
+	if strings.Trim(name, ". ") == "" {
+		return ""
+	}
+	return SanitizeFilename(name)
+}
+
+// Returns the Content-Type reported by the server, known once the download starts
+func (d *Downloader) ContentType() string {
+	return d.contentType
+}
+
+// the extensions mime.ExtensionsByType doesn't list first
+var preferredExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"text/plain": ".txt",
+	"text/html":  ".html",
+	"audio/mpeg": ".mp3",
+}
+
+// Returns the extension of a Content-Type, or empty string if there's
+// none. A generic binary type says nothing about the file, so it has none.
+func extensionByType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "application/octet-stream" {
+		return ""
+	}
+	if ext, ok := preferredExtensions[mediaType]; ok {
+		return ext
+	}
+
+	exts, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+	return exts[0]
+}
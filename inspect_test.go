@@ -0,0 +1,324 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	files := http.FileServer(http.Dir("./files/"))
+	mux := http.NewServeMux()
+	mux.Handle("/book.pdf", files)
+	mux.Handle("/download", http.RedirectHandler("/attachment", http.StatusFound))
+	mux.HandleFunc("/attachment", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="../report.pdf"`)
+		r.URL.Path = "/book.pdf"
+		files.ServeHTTP(w, r)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	d, err := New(server.URL + "/download")
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+
+	info, err := d.Inspect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.URL != server.URL+"/attachment" {
+		t.Errorf("Expected url to be %s, got %s", server.URL+"/attachment", info.URL)
+	}
+	if info.Size != 2142798 {
+		t.Errorf("Expected size to be 2142798, got %d", info.Size)
+	}
+	if !info.AcceptRanges {
+		t.Error("Expected ranges to be supported")
+	}
+	if info.ContentType != "application/pdf" {
+		t.Errorf("Expected content type to be application/pdf, got %s", info.ContentType)
+	}
+	if info.Filename != "report.pdf" {
+		t.Errorf("Expected filename to be report.pdf, got %s", info.Filename)
+	}
+
+	d, err = New(server.URL + "/missing")
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if _, err := d.Inspect(context.Background()); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestExtensionByType(t *testing.T) {
+	testCases := []struct {
+		ContentType string
+		Ext         string
+	}{
+		{ContentType: "application/pdf", Ext: ".pdf"},
+		{ContentType: "image/jpeg", Ext: ".jpg"},
+		{ContentType: "text/plain; charset=utf-8", Ext: ".txt"},
+		{ContentType: "application/octet-stream", Ext: ""},
+		{ContentType: "", Ext: ""},
+		{ContentType: "application/x-unknown-type", Ext: ""},
+	}
+
+	for _, testCase := range testCases {
+		if ext := extensionByType(testCase.ContentType); ext != testCase.Ext {
+			t.Errorf("%q: expected extension %q, got %q", testCase.ContentType, testCase.Ext, ext)
+		}
+	}
+}
+
+func TestInferExtension(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(original))
+	}))
+	defer server.Close()
+
+	outputDir, err := ioutil.TempDir("", "go_dl_infer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	d, err := NewFromConfig(&Config{
+		Url:            server.URL + "/download?id=42",
+		OutputDir:      outputDir,
+		InferExtension: true,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "download.pdf")); err != nil {
+		t.Errorf("Expected the file to be saved as download.pdf: %v", err)
+	}
+	if d.ContentType() != "application/pdf" {
+		t.Errorf("Expected content type application/pdf, got %s", d.ContentType())
+	}
+}
+
+func TestFilenameFunc(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `attachment; filename="report.bin"`)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader([]byte("%PDF-1.4")))
+	}))
+	defer server.Close()
+
+	outputDir, err := ioutil.TempDir("", "go_dl_filename_func")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+	if err := ioutil.WriteFile(filepath.Join(outputDir, "report.pdf"), []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		Func     func(info *RemoteInfo) string
+		Expected string
+	}{
+		// renamed since report.pdf exists
+		{Func: func(info *RemoteInfo) string {
+			if info.ContentType == "application/pdf" {
+				return strings.TrimSuffix(info.Filename, ".bin") + ".pdf"
+			}
+			return ""
+		}, Expected: "report(1).pdf"},
+		{Func: func(info *RemoteInfo) string { return "" }, Expected: "report.bin"},
+		{Func: func(info *RemoteInfo) string { return "../escaped.pdf" }, Expected: "escaped.pdf"},
+	}
+
+	for _, testCase := range testCases {
+		d, err := NewFromConfig(&Config{
+			Url:          server.URL + "/download",
+			OutputDir:    outputDir,
+			FilenameFunc: testCase.Func,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+		if err := d.Download(); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := filepath.Join(outputDir, testCase.Expected)
+		if d.OutputPath() != expected {
+			t.Errorf("Expected the file to be saved as %s, got %s", expected, d.OutputPath())
+		}
+		if _, err := os.Stat(expected); err != nil {
+			t.Errorf("Expected %s to exist: %v", expected, err)
+		}
+	}
+}
+
+func TestDispositionFilename(t *testing.T) {
+	testCases := []struct {
+		Disposition string
+		Filename    string
+	}{
+		{Disposition: `attachment; filename="report.pdf"`, Filename: "report.pdf"},
+		{Disposition: `attachment; filename="../../etc/passwd"`, Filename: "passwd"},
+		{Disposition: `attachment; filename="Q1: sales?.xlsx"`, Filename: "Q1_ sales_.xlsx"},
+		{Disposition: `attachment; filename=".."`, Filename: ""},
+		{Disposition: `inline`, Filename: ""},
+		{Disposition: ``, Filename: ""},
+	}
+
+	for _, testCase := range testCases {
+		actual := dispositionFilename(testCase.Disposition)
+		if actual != testCase.Filename {
+			t.Errorf("Expected filename of %q to be %q, got %q", testCase.Disposition, testCase.Filename, actual)
+		}
+	}
+}
+
+func TestOnRemoteInfo(t *testing.T) {
+	for _, noRanges := range []bool{false, true} {
+		server := newTestServer(t, []byte("0123456789"), testServerOptions{NoRanges: noRanges})
+		outFilename := tempOutFilename(t)
+
+		var info RemoteInfo
+		getsBefore := -1
+		logger := &recordingLogger{}
+		d, err := NewFromConfig(&Config{
+			Url:         server.URL + "/digits.txt",
+			Concurrency: 4,
+			OutFilename: outFilename,
+			Logger:      logger,
+			OnRemoteInfo: func(remote RemoteInfo) {
+				info = remote
+				getsBefore = 0
+				for _, req := range server.Requests() {
+					if req.Method == http.MethodGet {
+						getsBefore++
+					}
+				}
+			},
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+		if err := d.Download(); err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(outFilename)
+
+		if getsBefore != 0 {
+			t.Errorf("Expected OnRemoteInfo to be called before downloading, %d requests were sent", getsBefore)
+		}
+		if info.AcceptRanges == noRanges || info.Concurrent() == noRanges {
+			t.Errorf("Expected range support to be %v, got %+v", !noRanges, info)
+		}
+		if warned := logger.contains("doesn't support ranges"); warned != noRanges {
+			t.Errorf("Expected the single stream warning to be logged: %v, got %v", noRanges, warned)
+		}
+	}
+}
+
+func TestHeadFallback(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	testCases := []struct {
+		Name         string
+		RefuseHead   bool
+		IgnoreRanges bool
+		NoHead       bool
+		// 0 for a single stream
+		Parts int
+	}{
+		{"HEAD refused", true, false, false, 4},
+		{"HEAD refused, ranges ignored", true, true, false, 0},
+		{"NoHead", false, false, true, 4},
+	}
+
+	for _, testCase := range testCases {
+		var mu sync.Mutex
+		heads := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead {
+				mu.Lock()
+				heads++
+				mu.Unlock()
+				if testCase.RefuseHead {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+			}
+			if testCase.IgnoreRanges {
+				r.Header.Del("Range")
+			}
+			http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(original))
+		}))
+
+		outFilename := tempOutFilename(t)
+		d, err := NewFromConfig(&Config{
+			Url:         server.URL + "/book.pdf",
+			OutFilename: outFilename,
+			Concurrency: 4,
+			MinPartSize: 1,
+			NoHead:      testCase.NoHead,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+
+		info, err := d.Inspect(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", testCase.Name, err)
+		}
+		if info.Size != int64(len(original)) {
+			t.Errorf("%s: Expected size %d, got %d", testCase.Name, len(original), info.Size)
+		}
+		if info.AcceptRanges == testCase.IgnoreRanges {
+			t.Errorf("%s: Expected AcceptRanges %v, got %v", testCase.Name, !testCase.IgnoreRanges, info.AcceptRanges)
+		}
+
+		if err := d.Download(); err != nil {
+			t.Fatalf("%s: %v", testCase.Name, err)
+		}
+		if len(d.PartStats()) != testCase.Parts {
+			t.Errorf("%s: Expected %d parts, got %d", testCase.Name, testCase.Parts, len(d.PartStats()))
+		}
+		downloaded, err := ioutil.ReadFile(outFilename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(original, downloaded) {
+			t.Errorf("%s: Downloaded file is not the same as original file", testCase.Name)
+		}
+		mu.Lock()
+		if testCase.NoHead && heads != 0 {
+			t.Errorf("%s: Expected no HEAD request, got %d", testCase.Name, heads)
+		}
+		mu.Unlock()
+
+		os.Remove(outFilename)
+		server.Close()
+	}
+}
@@ -0,0 +1,42 @@
+package downloader
+
+// Logger receives the informational messages of the downloader,
+// *log.Logger satisfies it
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// LogLevel is how much is logged to Config.Logger
+type LogLevel int
+
+const (
+	// the milestones of the download, e.g. the output file and resuming
+	LogLevelNormal LogLevel = iota
+	// nothing, the errors are returned by Download
+	LogLevelQuiet
+	// also the range of each part, the retries and the mirrors they use
+	LogLevelVerbose
+)
+
+// discards everything, used when Config.Logger is nil
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, args ...interface{}) {}
+
+func (d *Downloader) logf(format string, args ...interface{}) {
+	if d.progress == nil {
+		d.logger.Printf(format, args...)
+		return
+	}
+
+	d.progress.aboveBar(func() {
+		d.logger.Printf(format, args...)
+	})
+}
+
+// Logs a detail which is only of interest with LogLevelVerbose
+func (d *Downloader) debugf(format string, args ...interface{}) {
+	if d.config.LogLevel == LogLevelVerbose {
+		d.logf(format, args...)
+	}
+}
@@ -0,0 +1,222 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// Manager downloads a batch of files, running at most
+// MaxConcurrentDownloads of them at the same time
+type Manager struct {
+	// at least one download runs at a time
+	MaxConcurrentDownloads int
+	// maximum total speed of the running downloads in bytes per second,
+	// on top of the MaxBytesPerSecond of each. Unlimited if zero.
+	MaxBytesPerSecond int64
+
+	configs []*Config
+	results []BatchResult
+
+	// guards the fields below, they're read while Run is running
+	mu sync.Mutex
+	// the downloader of each config, nil until it's started
+	downloaders []*Downloader
+	statuses    []jobStatus
+}
+
+// where a download of the batch is at
+type jobStatus int
+
+const (
+	jobQueued jobStatus = iota
+	jobActive
+	jobCompleted
+	jobFailed
+)
+
+// BatchCounts is the number of downloads of the batch in each stage
+type BatchCounts struct {
+	Queued    int
+	Active    int
+	Completed int
+	Failed    int
+}
+
+// BatchResult is the outcome of a single download of the batch
+type BatchResult struct {
+	Config *Config
+	// the file the download was saved to, empty if it wasn't started
+	OutputPath string
+	// nil if the download has succeeded
+	Err error
+}
+
+func NewManager(maxConcurrentDownloads int) *Manager {
+	return &Manager{MaxConcurrentDownloads: maxConcurrentDownloads}
+}
+
+// Add queues a download, it's started by Run
+func (m *Manager) Add(cfg *Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.configs = append(m.configs, cfg)
+	m.downloaders = append(m.downloaders, nil)
+	m.statuses = append(m.statuses, jobQueued)
+}
+
+// Run downloads all the queued files and waits for them to finish.
+// It returns an error if any of them has failed, see Results for details.
+func (m *Manager) Run(ctx context.Context) error {
+	workers := m.MaxConcurrentDownloads
+	if workers < 1 {
+		workers = 1
+	}
+
+	// a single limiter throttles all the downloads together
+	var limiter *rate.Limiter
+	if m.MaxBytesPerSecond > 0 {
+		limiter = newRateLimiter(m.MaxBytesPerSecond)
+	}
+
+	m.mu.Lock()
+	m.results = make([]BatchResult, len(m.configs))
+	for i := range m.statuses {
+		m.downloaders[i], m.statuses[i] = nil, jobQueued
+	}
+	m.mu.Unlock()
+
+	jobs := make(chan int)
+	wg := &sync.WaitGroup{}
+	wg.Add(workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				m.finish(i, m.download(ctx, i, limiter))
+			}
+		}()
+	}
+
+	for i := range m.configs {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			m.finish(i, ctx.Err())
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for _, result := range m.results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d downloads failed", failed, len(m.results))
+	}
+	return nil
+}
+
+func (m *Manager) download(ctx context.Context, i int, limiter *rate.Limiter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	d, err := NewFromConfig(m.configs[i])
+	if err != nil {
+		return err
+	}
+	d.managerLimiter = limiter
+	m.mu.Lock()
+	m.downloaders[i] = d
+	m.statuses[i] = jobActive
+	m.mu.Unlock()
+
+	return d.DownloadContext(ctx)
+}
+
+// Records the result of the i-th download
+func (m *Manager) finish(i int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results[i] = BatchResult{Config: m.configs[i], Err: err}
+	if d := m.downloaders[i]; d != nil {
+		m.results[i].OutputPath = d.OutputPath()
+	}
+	if err != nil {
+		m.statuses[i] = jobFailed
+	} else {
+		m.statuses[i] = jobCompleted
+	}
+}
+
+// TotalBytes returns the sum of the sizes of the started downloads,
+// the ones whose size is unknown count as zero. It can be called while
+// Run is running, e.g. to show the progress of the whole batch.
+func (m *Manager) TotalBytes() int64 {
+	var total int64
+	for _, d := range m.started() {
+		if size := d.Stats().Total; size > 0 {
+			total += size
+		}
+	}
+	return total
+}
+
+// DownloadedBytes returns the bytes downloaded so far by all downloads,
+// it can be called while Run is running
+func (m *Manager) DownloadedBytes() int64 {
+	var downloaded int64
+	for _, d := range m.started() {
+		downloaded += d.Stats().Downloaded
+	}
+	return downloaded
+}
+
+// Counts returns the number of queued, active, completed and failed
+// downloads, it can be called while Run is running
+func (m *Manager) Counts() BatchCounts {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := BatchCounts{}
+	for _, status := range m.statuses {
+		switch status {
+		case jobQueued:
+			counts.Queued++
+		case jobActive:
+			counts.Active++
+		case jobCompleted:
+			counts.Completed++
+		case jobFailed:
+			counts.Failed++
+		}
+	}
+	return counts
+}
+
+// Returns the downloaders which have been started
+func (m *Manager) started() []*Downloader {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	started := []*Downloader{}
+	for _, d := range m.downloaders {
+		if d != nil {
+			started = append(started, d)
+		}
+	}
+	return started
+}
+
+// Results returns the outcome of every download in the order they were
+// added. It must be called after Run has returned.
+func (m *Manager) Results() []BatchResult {
+	return m.results
+}
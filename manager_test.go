@@ -0,0 +1,156 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	files := http.FileServer(http.Dir("./files/"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+
+		files.ServeHTTP(w, r)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	m := NewManager(2)
+	for i := 0; i < 5; i++ {
+		outFilename := tempOutFilename(t)
+		defer os.Remove(outFilename)
+		m.Add(&Config{Url: server.URL + "/book.pdf", OutFilename: outFilename})
+	}
+	// has no url
+	m.Add(&Config{OutFilename: tempOutFilename(t)})
+
+	err := m.Run(context.Background())
+	if err == nil {
+		t.Error("Expected an error for the invalid config")
+	}
+
+	results := m.Results()
+	if len(results) != 6 {
+		t.Fatalf("Expected 6 results, got %d", len(results))
+	}
+	for i, result := range results[:5] {
+		if result.Err != nil {
+			t.Errorf("Expected download %d to succeed, got %v", i, result.Err)
+		}
+	}
+	if results[5].Err == nil {
+		t.Error("Expected the last download to fail")
+	}
+	if maxActive > 2 {
+		t.Errorf("Expected at most 2 concurrent requests, got %d", maxActive)
+	}
+}
+
+func TestManagerProgress(t *testing.T) {
+	data := make([]byte, 64*1024)
+	server := newTestServer(t, data, testServerOptions{Latency: 10 * time.Millisecond, ChunkSize: 4 * 1024})
+
+	m := NewManager(2)
+	for i := 0; i < 3; i++ {
+		outFilename := tempOutFilename(t)
+		defer os.Remove(outFilename)
+		m.Add(&Config{Url: server.URL + "/file.bin", OutFilename: outFilename, Concurrency: 2})
+	}
+	// has no url
+	m.Add(&Config{OutFilename: tempOutFilename(t)})
+
+	if counts := m.Counts(); counts.Queued != 4 {
+		t.Errorf("Expected 4 queued downloads before Run, got %+v", counts)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- m.Run(context.Background())
+	}()
+
+	sawActive := false
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-time.After(5 * time.Millisecond):
+			counts := m.Counts()
+			if counts.Active > 2 {
+				t.Errorf("Expected at most 2 active downloads, got %+v", counts)
+			}
+			if counts.Active > 0 {
+				sawActive = true
+			}
+			if downloaded, total := m.DownloadedBytes(), m.TotalBytes(); downloaded > total && total > 0 {
+				t.Errorf("Expected at most %d downloaded bytes, got %d", total, downloaded)
+			}
+		}
+	}
+	if !sawActive {
+		t.Error("Expected active downloads while running")
+	}
+
+	expected := BatchCounts{Completed: 3, Failed: 1}
+	if counts := m.Counts(); counts != expected {
+		t.Errorf("Expected %+v, got %+v", expected, counts)
+	}
+	if total := m.TotalBytes(); total != 3*int64(len(data)) {
+		t.Errorf("Expected total of %d bytes, got %d", 3*len(data), total)
+	}
+	if downloaded := m.DownloadedBytes(); downloaded != 3*int64(len(data)) {
+		t.Errorf("Expected %d downloaded bytes, got %d", 3*len(data), downloaded)
+	}
+}
+
+func TestManagerMaxBytesPerSecond(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 2*1024)
+	server := newTestServer(t, data, testServerOptions{})
+
+	m := NewManager(3)
+	m.MaxBytesPerSecond = int64(len(data))
+	var outFilenames []string
+	for i := 0; i < 3; i++ {
+		outFilename := tempOutFilename(t)
+		defer os.Remove(outFilename)
+		outFilenames = append(outFilenames, outFilename)
+		m.Add(&Config{Url: server.URL, OutFilename: outFilename, Concurrency: 2, MinPartSize: 1})
+	}
+
+	start := time.Now()
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+
+	// the limit is shared, the first second's worth is allowed
+	// immediately by the burst and the rest takes ~2s
+	if elapsed < 1500*time.Millisecond {
+		t.Errorf("Expected the downloads to be throttled together to ~2s, took %v", elapsed)
+	}
+	for _, outFilename := range outFilenames {
+		downloaded, err := ioutil.ReadFile(outFilename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, downloaded) {
+			t.Error("Downloaded file is not the same as original file")
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package downloader
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestEntry is a file listed in a manifest
+type ManifestEntry struct {
+	Url string `json:"url"`
+	// path of the saved file relative to the output directory,
+	// detected from the url if empty
+	Filename string `json:"filename"`
+	// the file isn't verified if empty
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest lists the files to download and verify, e.g. the files of a dataset
+type Manifest struct {
+	Entries []ManifestEntry
+}
+
+// LoadManifest reads the manifest file at path, see ParseManifest
+func LoadManifest(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseManifest(f)
+}
+
+// ParseManifest reads a manifest, either a JSON array of objects with the
+// url, filename and sha256 fields, or CSV lines of url,filename,sha256 where
+// the last two are optional. A CSV header line and lines starting with #
+// are skipped.
+func ParseManifest(r io.Reader) (*Manifest, error) {
+	reader := bufio.NewReader(r)
+	var entries []ManifestEntry
+	var err error
+	if isJSONManifest(reader) {
+		err = json.NewDecoder(reader).Decode(&entries)
+	} else {
+		entries, err = parseCSVManifest(reader)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid manifest: %w", err)
+	}
+
+	for i, entry := range entries {
+		if strings.TrimSpace(entry.Url) == "" {
+			return nil, fmt.Errorf("Invalid manifest: entry %d has no url", i+1)
+		}
+		if !isLocalPath(entry.Filename) {
+			return nil, fmt.Errorf("Invalid manifest: filename %s of entry %d is outside the output directory", entry.Filename, i+1)
+		}
+	}
+	return &Manifest{Entries: entries}, nil
+}
+
+// Reports whether the manifest starts with a JSON array
+func isJSONManifest(r *bufio.Reader) bool {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return false
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b[0])) {
+			return b[0] == '['
+		}
+		r.ReadByte()
+	}
+}
+
+func parseCSVManifest(r io.Reader) ([]ManifestEntry, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var entries []ManifestEntry
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) > 3 {
+			return nil, fmt.Errorf("line %d has %d fields, expected url,filename,sha256", line, len(record))
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "url") {
+			// header
+			continue
+		}
+
+		record = append(record, "", "")
+		entries = append(entries, ManifestEntry{
+			Url:      strings.TrimSpace(record[0]),
+			Filename: strings.TrimSpace(record[1]),
+			SHA256:   strings.TrimSpace(record[2]),
+		})
+	}
+}
+
+// Reports whether the relative path name stays inside its directory,
+// so a manifest can't overwrite files elsewhere
+func isLocalPath(name string) bool {
+	if name == "" {
+		return true
+	}
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") {
+		return false
+	}
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	return cleaned != ".." && !strings.HasPrefix(cleaned, ".."+string(filepath.Separator))
+}
+
+// Configs returns a copy of base for each entry, with its url, filename
+// and expected checksum
+func (m *Manifest) Configs(base Config) []*Config {
+	configs := make([]*Config, len(m.Entries))
+	for i, entry := range m.Entries {
+		c := base
+		c.Url = entry.Url
+		c.OutFilename = ""
+		c.Filename = filepath.FromSlash(entry.Filename)
+		c.ExpectedSHA256 = entry.SHA256
+		configs[i] = &c
+	}
+	return configs
+}
+
+// AddTo queues the entries in the manager with copies of base, see Configs
+func (m *Manifest) AddTo(manager *Manager, base Config) {
+	for _, c := range m.Configs(base) {
+		manager.Add(c)
+	}
+}
+
+// VerificationFailures returns the results of the downloads
+// whose file doesn't match its checksum
+func VerificationFailures(results []BatchResult) []BatchResult {
+	var failures []BatchResult
+	for _, result := range results {
+		if errors.Is(result.Err, ErrChecksumMismatch) {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
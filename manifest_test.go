@@ -0,0 +1,104 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	sha := strings.Repeat("ab", 32)
+	expected := []ManifestEntry{
+		{Url: "http://example.com/a.csv", Filename: "data/a.csv", SHA256: sha},
+		{Url: "http://example.com/b.csv"},
+	}
+
+	testCases := []struct {
+		Name    string
+		Content string
+	}{
+		{"json", `
+			[
+				{"url": "http://example.com/a.csv", "filename": "data/a.csv", "sha256": "` + sha + `"},
+				{"url": "http://example.com/b.csv"}
+			]`},
+		{"csv", "http://example.com/a.csv,data/a.csv," + sha + "\nhttp://example.com/b.csv\n"},
+		{"csv with header", "url,filename,sha256\n# the tables\nhttp://example.com/a.csv, data/a.csv, " + sha + "\nhttp://example.com/b.csv,,\n"},
+	}
+
+	for _, testCase := range testCases {
+		manifest, err := ParseManifest(strings.NewReader(testCase.Content))
+		if err != nil {
+			t.Errorf("%s: Expected the manifest to parse, got %v", testCase.Name, err)
+			continue
+		}
+		if !reflect.DeepEqual(manifest.Entries, expected) {
+			t.Errorf("%s: Expected %v, got %v", testCase.Name, expected, manifest.Entries)
+		}
+	}
+
+	invalid := []string{
+		`[{"filename": "a.csv"}]`,
+		`[{"url": "http://example.com/a.csv", "filename": "../a.csv"}]`,
+		"http://example.com/a.csv,/etc/passwd\n",
+		"http://example.com/a.csv,a.csv," + sha + ",extra\n",
+		`[{"url": `,
+	}
+	for _, content := range invalid {
+		if _, err := ParseManifest(strings.NewReader(content)); err == nil {
+			t.Errorf("Expected an error for manifest %q", content)
+		}
+	}
+}
+
+func TestManifestDownload(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	sum := sha256.Sum256(original)
+
+	dir, err := ioutil.TempDir("", "go_dl_manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest, err := ParseManifest(strings.NewReader(
+		server.URL + "/book.pdf,books/good.pdf," + hex.EncodeToString(sum[:]) + "\n" +
+			server.URL + "/book.pdf,books/bad.pdf," + strings.Repeat("00", 32) + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(2)
+	manifest.AddTo(m, Config{OutputDir: dir, Concurrency: 2})
+	if err := m.Run(context.Background()); err == nil {
+		t.Error("Expected the batch to fail")
+	}
+
+	failures := VerificationFailures(m.Results())
+	if len(failures) != 1 || filepath.Base(failures[0].OutputPath) != "bad.pdf" {
+		t.Fatalf("Expected bad.pdf to fail verification, got %v", failures)
+	}
+
+	downloaded, err := ioutil.ReadFile(filepath.Join(dir, "books", "good.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, downloaded) {
+		t.Error("Downloaded file is not the same as original file")
+	}
+}
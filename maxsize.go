@@ -0,0 +1,51 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Returned when the file is larger than Config.MaxSize
+var ErrFileTooLarge = errors.New("File is larger than the maximum size")
+
+// Returns ErrFileTooLarge if size is over MaxSize
+func (d *Downloader) checkMaxSize(size int64) error {
+	if d.config.MaxSize > 0 && size > d.config.MaxSize {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrFileTooLarge, size, d.config.MaxSize)
+	}
+
+	return nil
+}
+
+// Fails the reads of body with ErrFileTooLarge once the file, which
+// already has offset bytes, would grow over MaxSize
+func (d *Downloader) limitSize(body io.Reader, offset int64) io.Reader {
+	if d.config.MaxSize <= 0 {
+		return body
+	}
+
+	return &maxSizeReader{reader: body, remaining: d.config.MaxSize - offset, max: d.config.MaxSize}
+}
+
+type maxSizeReader struct {
+	reader    io.Reader
+	remaining int64
+	max       int64
+}
+
+func (r *maxSizeReader) Read(p []byte) (int, error) {
+	// one more byte than allowed tells whether there's more
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+
+	n, err := r.reader.Read(p)
+	if int64(n) > r.remaining {
+		n = int(r.remaining)
+		r.remaining = 0
+		return n, fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, r.max)
+	}
+	r.remaining -= int64(n)
+	return n, err
+}
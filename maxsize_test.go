@@ -0,0 +1,109 @@
+package downloader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestMaxSize(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	server := newTestServer(t, original, testServerOptions{})
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 2,
+		OutFilename: outFilename,
+		MaxSize:     1024 * 1024,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+
+	for _, r := range server.Requests() {
+		if r.Method != http.MethodHead {
+			t.Errorf("Expected only the HEAD request, got %s", r.Method)
+		}
+	}
+	if _, err := os.Stat(outFilename); !os.IsNotExist(err) {
+		t.Error("Expected no output file")
+	}
+}
+
+func TestMaxSizeWithoutLength(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(original)
+	gz.Close()
+
+	testCases := []struct {
+		Gzip bool
+	}{
+		{Gzip: false},
+		{Gzip: true},
+	}
+
+	for _, testCase := range testCases {
+		// neither the HEAD nor the GET response has a length
+		useGzip := testCase.Gzip
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := original
+			if useGzip {
+				w.Header().Set("Content-Encoding", "gzip")
+				body = compressed.Bytes()
+			}
+			if r.Method == http.MethodHead {
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			w.Write(body)
+		}))
+		defer server.Close()
+
+		outFilename := tempOutFilename(t)
+		defer os.Remove(outFilename + defaultIncompleteSuffix)
+
+		// the compressed body is below the limit, the file isn't
+		maxSize := int64(len(compressed.Bytes()) + 1)
+		d, err := NewFromConfig(&Config{
+			Url:         server.URL + "/book.pdf",
+			OutFilename: outFilename,
+			MaxSize:     maxSize,
+			Decompress:  useGzip,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+		if err := d.Download(); !errors.Is(err, ErrFileTooLarge) {
+			t.Errorf("gzip %v: expected ErrFileTooLarge, got %v", useGzip, err)
+		}
+
+		// the file is left incomplete
+		info, err := os.Stat(outFilename + defaultIncompleteSuffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > maxSize {
+			t.Errorf("gzip %v: expected at most %d bytes written, got %d", useGzip, maxSize, info.Size())
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// mergedFile is the temporary file finished parts are appended to,
+// in order. It's moved to the output path once all parts are merged.
+type mergedFile struct {
+	file WriteSeekCloser
+	// writes to the file and the checksums
+	writer io.Writer
+	sums   []*checksum
+}
+
+// Parts are merged into the incomplete file, so tools ignoring
+// IncompleteSuffix don't pick up the file before it's completed
+func (d *Downloader) mergedFilename() string {
+	return d.incompleteFilename()
+}
+
+// Moves the merged file of a version which merged the parts next to them
+// to the incomplete file, so a download started by it can be resumed
+func (d *Downloader) adoptLegacyMergedFile() error {
+	if _, err := d.storage.Stat(d.mergedFilename()); !os.IsNotExist(err) {
+		return nil
+	}
+	legacy := d.partsPrefix() + ".tmp"
+	if _, err := d.storage.Stat(legacy); err != nil {
+		return nil
+	}
+	return d.moveFile(legacy, d.mergedFilename())
+}
+
+// Opens the merged file positioned after the parts which are already
+// merged. Anything after them (e.g. from a crash in the middle of an
+// append) is dropped, that part file is still there to be merged again.
+func (d *Downloader) openMergedFile(meta *metadata) (*mergedFile, error) {
+	if err := d.adoptLegacyMergedFile(); err != nil {
+		return nil, err
+	}
+	f, err := d.storage.Create(d.mergedFilename())
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(meta.mergedSize())
+	if err := d.storage.Truncate(d.mergedFilename(), size); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// parts merged in a previous run have to be hashed again
+	sums := d.checksums()
+	if err := d.hashFile(d.mergedFilename(), size, sums); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &mergedFile{file: f, writer: checksumWriter(f, sums), sums: sums}, nil
+}
+
+// Appends a finished part to the merged file and removes it, unless
+// KeepParts is set. The part is removed only after the metadata records
+// it's merged, so it's never lost.
+func (d *Downloader) mergePart(merged *mergedFile, meta *metadata, partNum int) error {
+	filename := d.getPartFilename(partNum)
+	written, err := d.appendPart(merged.writer, filename)
+	if err != nil {
+		return err
+	}
+	// a connection closed early looks like a finished part,
+	// merging it would shift every byte after it
+	part := meta.Parts[partNum-1]
+	if expected := int64(part.Stop - part.Start + 1); written != expected {
+		return fmt.Errorf("Part %d has %d bytes, expected %d", partNum, written, expected)
+	}
+
+	meta.Merged = partNum
+	if err := d.saveMetadata(meta); err != nil {
+		return err
+	}
+
+	if d.config.KeepParts {
+		return nil
+	}
+	if err := d.storage.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Copies a part file to w, a part file which was never
+// created is empty and its size is checked by the caller
+func (d *Downloader) appendPart(w io.Writer, filename string) (int64, error) {
+	source, err := d.storage.Open(filename)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+
+	return io.Copy(w, source)
+}
+
+// Makes sure the merged file has the size of the remote file
+// and reports the parts which are short if it doesn't
+func (d *Downloader) verifyMergedSize(merged *mergedFile, contentSize int) error {
+	fileInfo, err := d.storage.Stat(d.mergedFilename())
+	if err != nil {
+		return err
+	}
+	if fileInfo.Size() == int64(contentSize) {
+		return nil
+	}
+
+	return fmt.Errorf("Downloaded file has %d bytes, expected %d (short parts: %s)",
+		fileInfo.Size(), contentSize, strings.Join(d.shortParts(), ", "))
+}
+
+// Returns the numbers of the parts with fewer bytes than their range
+func (d *Downloader) shortParts() []string {
+	var short []string
+	for _, stat := range d.PartStats() {
+		if stat.Downloaded != stat.Stop-stat.Start+1 {
+			short = append(short, strconv.Itoa(stat.Part))
+		}
+	}
+
+	return short
+}
+
+// MergeParts concatenates the part files in order into outPath, e.g. to
+// salvage the parts of an interrupted download without downloading them
+// again. The parts are kept. If expectedSHA256 isn't empty the merged
+// file must match it, otherwise it's not created and the error wraps
+// ErrChecksumMismatch.
+func MergeParts(outPath string, partPaths []string, expectedSHA256 string) error {
+	if len(partPaths) == 0 {
+		return errors.New("No part files to merge")
+	}
+	var sums []*checksum
+	if expectedSHA256 != "" {
+		sums = append(sums, &checksum{algorithm: "sha256", expected: expectedSHA256, hash: sha256.New()})
+	}
+
+	// outPath only appears once all the parts are merged
+	tmpPath := outPath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = mergeParts(checksumWriter(f, sums), partPaths)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = verifyChecksums(sums)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, outPath)
+}
+
+// Copies the part files to w in order, unlike appendPart a missing one
+// is an error since its range is unknown
+func mergeParts(w io.Writer, partPaths []string) error {
+	for _, partPath := range partPaths {
+		part, err := os.Open(partPath)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, part)
+		part.Close()
+		if err != nil {
+			return fmt.Errorf("part %s: %w", partPath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package downloader
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResumeAfterPartialMerge(t *testing.T) {
+	testResumeAfterPartialMerge(t, false)
+}
+
+// the parts merged by a version which merged them next to the part files
+func TestResumeLegacyMergedFile(t *testing.T) {
+	testResumeAfterPartialMerge(t, true)
+}
+
+func testResumeAfterPartialMerge(t *testing.T, legacy bool) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	firstPart := splitRanges(len(original), 4)[0]
+	firstRange := fmt.Sprintf("bytes=0-%d", firstPart.Stop)
+
+	var mu sync.Mutex
+	healthy := false
+	firstPartRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ok := healthy
+		if r.Header.Get("Range") == firstRange {
+			firstPartRequests++
+			ok = true
+		}
+		mu.Unlock()
+
+		if r.Method == http.MethodGet && !ok {
+			// fail the other parts after the first one is merged
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(original))
+	}))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+	defer os.Remove(outFilename + defaultIncompleteSuffix)
+
+	config := Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 4,
+		MinPartSize: 1,
+		OutFilename: outFilename,
+	}
+	d, err := NewFromConfig(&config)
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err == nil {
+		t.Fatal("Expected the first download to fail")
+	}
+	if _, err := os.Stat(d.getPartFilename(1)); !os.IsNotExist(err) {
+		t.Error("Expected the first part to be removed once merged")
+	}
+	if _, err := os.Stat(outFilename + defaultIncompleteSuffix); err != nil {
+		t.Errorf("Expected the parts to be merged into the incomplete file, got %v", err)
+	}
+	if legacy {
+		defer os.Remove(d.partsPrefix() + ".tmp")
+		if err := os.Rename(outFilename+defaultIncompleteSuffix, d.partsPrefix()+".tmp"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mu.Lock()
+	healthy = true
+	mu.Unlock()
+
+	config.Resume = true
+	d, err = NewFromConfig(&config)
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	downloaded, err := ioutil.ReadFile(outFilename)
+	if err != nil {
+		t.Fatalf("Cannot read %s", outFilename)
+	}
+	if !bytes.Equal(original, downloaded) {
+		t.Error("Downloaded file is not the same as original file")
+	}
+	if firstPartRequests != 1 {
+		t.Errorf("Expected the merged part to be downloaded once, got %d requests", firstPartRequests)
+	}
+}
+
+func TestShortPartFails(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	// the second part ends early without an error, as a dropped
+	// connection of a response without Content-Length would
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, stop int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &stop); err == nil && start > 0 && stop < len(original)-1 {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, stop, len(original)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.(http.Flusher).Flush()
+			w.Write(original[start : start+(stop-start)/2])
+			return
+		}
+		http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(original))
+	}))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 3,
+		MinPartSize: 1,
+		OutFilename: outFilename,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	defer d.Cleanup()
+
+	err = d.Download()
+	if err == nil || !strings.Contains(err.Error(), "Part 2") {
+		t.Errorf("Expected an error about part 2, got %v", err)
+	}
+}
+
+func TestKeepParts(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	server := newTestServer(t, original, testServerOptions{})
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 3,
+		MinPartSize: 1,
+		OutFilename: outFilename,
+		KeepParts:   true,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	defer d.Cleanup()
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the parts put together are the file
+	var parts []byte
+	for i := 1; i <= 3; i++ {
+		part, err := ioutil.ReadFile(d.getPartFilename(i))
+		if err != nil {
+			t.Fatalf("Expected part %d to be kept: %v", i, err)
+		}
+		parts = append(parts, part...)
+	}
+	if !bytes.Equal(original, parts) {
+		t.Error("Expected the kept parts to make up the original file")
+	}
+}
+
+func TestMergeParts(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	dir, err := ioutil.TempDir("", "go_dl_merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var partPaths []string
+	for i, part := range splitRanges(len(original), 3) {
+		partPath := filepath.Join(dir, fmt.Sprintf("book.pdf.part%d", i+1))
+		if err := ioutil.WriteFile(partPath, original[part.Start:part.Stop+1], 0644); err != nil {
+			t.Fatal(err)
+		}
+		partPaths = append(partPaths, partPath)
+	}
+	sum := sha256.Sum256(original)
+	outPath := filepath.Join(dir, "book.pdf")
+
+	if err := MergeParts(outPath, partPaths, hex.EncodeToString(sum[:])); err != nil {
+		t.Fatal(err)
+	}
+	merged, err := ioutil.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, merged) {
+		t.Error("Merged file is not the same as original file")
+	}
+	os.Remove(outPath)
+
+	// the parts out of order don't match the checksum
+	reversed := []string{partPaths[2], partPaths[1], partPaths[0]}
+	if err := MergeParts(outPath, reversed, hex.EncodeToString(sum[:])); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Error("Expected no merged file after a checksum mismatch")
+	}
+
+	if err := MergeParts(outPath, append(partPaths, filepath.Join(dir, "missing")), ""); err == nil {
+		t.Error("Expected an error for a missing part")
+	}
+	for _, partPath := range partPaths {
+		if _, err := os.Stat(partPath); err != nil {
+			t.Errorf("Expected the part %s to be kept, got %v", partPath, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// metadata is stored next to the output file while a concurrent
+// download is in progress, so a later resume can make sure it
+// continues the very same download
+type metadata struct {
+	Url          string      `json:"url"`
+	Size         int         `json:"size"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	Concurrency  int         `json:"concurrency"`
+	Parts        []partRange `json:"parts"`
+	// number of leading parts already appended to the merged file
+	Merged int `json:"merged"`
+	// bytes written of each part with SinglePreallocatedFile,
+	// the part files are merged otherwise and it's nil
+	Written []int `json:"written,omitempty"`
+	// RangeStart of a sub-range download, the parts are relative to it
+	Offset int `json:"offset,omitempty"`
+	// bytes in each part file when the download was paused, a resume
+	// drops anything written after them. Nil unless it was paused.
+	Paused []int `json:"paused,omitempty"`
+}
+
+// byte range of a part, both ends are inclusive
+type partRange struct {
+	Start int `json:"start"`
+	Stop  int `json:"stop"`
+}
+
+func (d *Downloader) metadataFilename() string {
+	return d.partsPrefix() + ".godl.json"
+}
+
+// Returns nil if there is no metadata file
+func (d *Downloader) loadMetadata() (*metadata, error) {
+	data, err := d.readFile(d.metadataFilename())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	m := &metadata{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid metadata file %s: %w", d.metadataFilename(), err)
+	}
+	return m, nil
+}
+
+func (d *Downloader) saveMetadata(m *metadata) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile(d.metadataFilename(), data, d.config.FlushOnPause && d.isPaused())
+}
+
+// Returns the size of the parts appended to the merged file
+func (m *metadata) mergedSize() int {
+	if m.Merged == 0 {
+		return 0
+	}
+
+	return m.Parts[m.Merged-1].Stop + 1
+}
+
+// Returns the validator to send as If-Range, weak ETags
+// are not allowed there so Last-Modified is used instead
+func (m *metadata) ifRange() string {
+	if m.ETag != "" && !strings.HasPrefix(m.ETag, "W/") {
+		return m.ETag
+	}
+
+	return m.LastModified
+}
+
+// Checks the current download matches the saved one
+func (m *metadata) validate(current *metadata) error {
+	mismatch := func(field string, saved, now interface{}) error {
+		return fmt.Errorf("Cannot resume: %s has changed from %v to %v, the download must be restarted", field, saved, now)
+	}
+
+	if m.Url != current.Url {
+		return mismatch("url", m.Url, current.Url)
+	}
+	if m.Size != current.Size {
+		return mismatch("file size", m.Size, current.Size)
+	}
+	if m.Offset != current.Offset {
+		return mismatch("range start", m.Offset, current.Offset)
+	}
+	if m.ETag != "" && m.ETag != current.ETag {
+		return mismatch("ETag", m.ETag, current.ETag)
+	}
+	if m.LastModified != "" && m.LastModified != current.LastModified {
+		return mismatch("Last-Modified", m.LastModified, current.LastModified)
+	}
+	if (m.Written != nil) != (current.Written != nil) {
+		return mismatch("single preallocated file", m.Written != nil, current.Written != nil)
+	}
+
+	// the saved parts are used whatever the concurrency is now,
+	// so they have to cover the whole file
+	next := 0
+	for i, part := range m.Parts {
+		if part.Start != next || part.Stop < part.Start {
+			return fmt.Errorf("Cannot resume: invalid range of part %d in %s", i+1, m.Url)
+		}
+		next = part.Stop + 1
+	}
+	if next != m.Size || (m.Written != nil && len(m.Written) != len(m.Parts)) || (m.Paused != nil && len(m.Paused) != len(m.Parts)) {
+		return errors.New("Cannot resume: the saved parts don't match the file size, the download must be restarted")
+	}
+
+	return nil
+}
+
+// Records the bytes in each part file once the parts have stopped, so a
+// resume continues exactly from them, even if the part files have grown
+// since, e.g. by a write that was still buffered.
+func (d *Downloader) savePausedParts(meta *metadata, partsDone []*sync.WaitGroup) error {
+	d.stopParts(partsDone)
+
+	meta.Paused = make([]int, len(meta.Parts))
+	d.partsMu.Lock()
+	for i, stat := range d.partStats {
+		// a part which couldn't be flushed is downloaded again
+		if !d.unsynced[i] {
+			meta.Paused[i] = int(stat.Downloaded)
+		}
+	}
+	d.partsMu.Unlock()
+	return d.saveMetadata(meta)
+}
+
+// Cuts the part files to the sizes recorded when the download was
+// paused. A shorter part file is continued from its own size.
+func (d *Downloader) restorePausedParts(meta *metadata) error {
+	if meta.Paused == nil {
+		return nil
+	}
+
+	for i := meta.Merged; i < len(meta.Parts); i++ {
+		filename := d.getPartFilename(i + 1)
+		fileInfo, err := d.storage.Stat(filename)
+		if err != nil {
+			continue
+		}
+		if fileInfo.Size() > int64(meta.Paused[i]) {
+			d.logf("Part %d has %d bytes after the pause, continuing from %d", i+1, fileInfo.Size(), meta.Paused[i])
+			if err := d.storage.Truncate(filename, int64(meta.Paused[i])); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the sizes are stale once the download continues
+	meta.Paused = nil
+	return d.saveMetadata(meta)
+}
@@ -0,0 +1,548 @@
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResumeFailsWhenRemoteFileChanged(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	var mu sync.Mutex
+	etag := `"v1"`
+	failParts := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		w.Header().Set("ETag", etag)
+		fail := failParts && r.Method == http.MethodGet
+		mu.Unlock()
+
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(original))
+	}))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 2,
+		OutFilename: outFilename,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	defer func() {
+		os.Remove(d.metadataFilename())
+		for i := 1; i <= 2; i++ {
+			os.Remove(d.getPartFilename(i))
+		}
+	}()
+
+	if err := d.Download(); err == nil {
+		t.Fatal("Expected the first download to fail")
+	}
+	if _, err := os.Stat(d.metadataFilename()); err != nil {
+		t.Fatalf("Expected metadata file to be kept, got %v", err)
+	}
+
+	// the file changes on the server before resuming
+	mu.Lock()
+	etag = `"v2"`
+	failParts = false
+	mu.Unlock()
+
+	// a new process resumes the download
+	d, err = NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 2,
+		OutFilename: outFilename,
+		Resume:      true,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	err = d.Download()
+	if err == nil || !strings.Contains(err.Error(), "ETag") {
+		t.Errorf("Expected resume to fail because of the changed ETag, got %v", err)
+	}
+}
+
+func TestIfRangeDetectsChangedFile(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	// the file changes between the HEAD and the range requests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("ETag", `"v1"`)
+		} else {
+			w.Header().Set("ETag", `"v2"`)
+		}
+		http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(original))
+	}))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 2,
+		OutFilename: outFilename,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	defer os.Remove(d.metadataFilename())
+
+	if err := d.Download(); !errors.Is(err, ErrRemoteFileChanged) {
+		t.Errorf("Expected ErrRemoteFileChanged, got %v", err)
+	}
+}
+
+func TestResumeWithDifferentConcurrency(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	server := newTestServer(t, original, testServerOptions{
+		Latency:   10 * time.Millisecond,
+		ChunkSize: 16 * 1024,
+	})
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	// the first run is paused in the middle
+	var d *Downloader
+	d, err = NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 4,
+		MinPartSize: 1,
+		OutFilename: outFilename,
+		OnProgress: func(downloaded, total int64) {
+			if downloaded > total/3 {
+				d.Pause()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	defer d.Cleanup()
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+	if d.State() != StatePaused {
+		t.Fatalf("Expected the first run to be paused, got %s", d.State())
+	}
+
+	// a new process resumes it with another concurrency
+	logger := &recordingLogger{}
+	d, err = NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 3,
+		MinPartSize: 1,
+		OutFilename: outFilename,
+		Resume:      true,
+		Logger:      logger,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	downloaded, err := ioutil.ReadFile(outFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, downloaded) {
+		t.Error("Downloaded file differs from the original")
+	}
+	if len(d.PartStats()) != 4 {
+		t.Errorf("Expected the original 4 parts, got %d", len(d.PartStats()))
+	}
+	if !logger.contains("original 4 parts") {
+		t.Error("Expected the original partitioning to be logged")
+	}
+}
+
+func TestResumeWithoutMetadataDifferentConcurrency(t *testing.T) {
+	server := newTestServer(t, []byte("0123456789"), testServerOptions{})
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/digits.txt",
+		Concurrency: 2,
+		MinPartSize: 1,
+		OutFilename: outFilename,
+		Resume:      true,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	defer d.Cleanup()
+
+	// left by a run with 3 parts, which didn't write metadata
+	for i, content := range []string{"012", "345", "6"} {
+		if err := ioutil.WriteFile(d.getPartFilename(i+1), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = d.Download()
+	if err == nil || !strings.Contains(err.Error(), "concurrency") {
+		t.Errorf("Expected resume to fail because of the concurrency, got %v", err)
+	}
+}
+
+func TestResumeIgnoresForeignParts(t *testing.T) {
+	server := newTestServer(t, []byte("0123456789"), testServerOptions{})
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/digits.txt",
+		Concurrency: 2,
+		MinPartSize: 1,
+		OutFilename: outFilename,
+		Resume:      true,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	defer d.Cleanup()
+
+	// left by downloads of other urls to the same file
+	foreign := []string{outFilename + ".part1", outFilename + "." + urlHash(server.URL+"/other.txt") + ".part1"}
+	for _, name := range foreign {
+		if err := ioutil.WriteFile(name, []byte("abcde"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(name)
+	}
+
+	if err := d.Download(); err != nil {
+		t.Fatalf("Expected the download to succeed, got %v", err)
+	}
+	content, _ := ioutil.ReadFile(outFilename)
+	if string(content) != "0123456789" {
+		t.Errorf("Expected 0123456789, got %q", content)
+	}
+	for _, name := range foreign {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("Expected %s to be left alone, got %v", name, err)
+		}
+	}
+}
+
+func TestPauseSavesPartOffsets(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	server := newTestServer(t, original, testServerOptions{
+		Latency:   10 * time.Millisecond,
+		ChunkSize: 16 * 1024,
+	})
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	var d *Downloader
+	d, err = NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 4,
+		MinPartSize: 1,
+		OutFilename: outFilename,
+		OnProgress: func(downloaded, total int64) {
+			if downloaded > total/3 {
+				d.Pause()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	defer d.Cleanup()
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := d.loadMetadata()
+	if err != nil || meta == nil {
+		t.Fatalf("Expected the metadata to be saved, got %v", err)
+	}
+	if len(meta.Paused) != len(meta.Parts) {
+		t.Fatalf("Expected the offsets of %d parts, got %v", len(meta.Parts), meta.Paused)
+	}
+	for i := meta.Merged; i < len(meta.Parts); i++ {
+		size := 0
+		if fileInfo, err := os.Stat(d.getPartFilename(i + 1)); err == nil {
+			size = int(fileInfo.Size())
+		}
+		if meta.Paused[i] != size {
+			t.Errorf("Expected offset of part %d to be %d, got %d", i+1, size, meta.Paused[i])
+		}
+	}
+
+	// bytes written after the metadata are dropped on resume
+	last := len(meta.Parts)
+	f, err := os.OpenFile(d.getPartFilename(last), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("garbage"))
+	f.Close()
+
+	d, err = NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 4,
+		MinPartSize: 1,
+		OutFilename: outFilename,
+		Resume:      true,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	downloaded, err := ioutil.ReadFile(outFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, downloaded) {
+		t.Error("Downloaded file differs from the original")
+	}
+}
+
+func TestResumeVerifyTail(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	server := newTestServer(t, data, testServerOptions{})
+
+	for _, verifyTail := range []int{0, 3, 100} {
+		outFilename := tempOutFilename(t)
+		defer os.Remove(outFilename)
+
+		d, err := NewFromConfig(&Config{
+			Url:              server.URL + "/file.bin",
+			Concurrency:      2,
+			MinPartSize:      1,
+			OutFilename:      outFilename,
+			Resume:           true,
+			ResumeVerifyTail: verifyTail,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+		defer d.Cleanup()
+
+		// the last write of the first part was torn
+		for i, content := range []string{"0123X", "abcde"} {
+			if err := ioutil.WriteFile(d.getPartFilename(i+1), []byte(content), 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := d.Download(); err != nil {
+			t.Fatal(err)
+		}
+		downloaded, _ := ioutil.ReadFile(outFilename)
+		if verified := bytes.Equal(data, downloaded); verified != (verifyTail > 0) {
+			t.Errorf("Verifying %d bytes: expected the file to be correct: %v, got %q", verifyTail, verifyTail > 0, downloaded)
+		}
+	}
+}
+
+func TestFlushOnPause(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	server := newTestServer(t, original, testServerOptions{
+		Latency:   10 * time.Millisecond,
+		ChunkSize: 16 * 1024,
+	})
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+	config := &Config{
+		Url:          server.URL + "/book.pdf",
+		Concurrency:  4,
+		MinPartSize:  1,
+		OutFilename:  outFilename,
+		FlushOnPause: true,
+	}
+
+	d, err := NewFromConfig(config)
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	defer d.Cleanup()
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Download()
+	}()
+	for d.Stats().Downloaded <= int64(len(original)/3) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// the process could exit as soon as Pause returns
+	d.Pause()
+	if d.State() != StatePaused {
+		t.Errorf("Expected state to be %s once Pause returns, got %s", StatePaused, d.State())
+	}
+	meta, err := d.loadMetadata()
+	if err != nil || meta == nil || len(meta.Paused) != len(meta.Parts) {
+		t.Fatalf("Expected the paused offsets to be saved once Pause returns, got %v, %v", meta, err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// a new process resumes the download
+	requests := len(server.Requests())
+	resumeConfig := *config
+	resumeConfig.Resume = true
+	d, err = NewFromConfig(&resumeConfig)
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	downloaded, err := ioutil.ReadFile(outFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, downloaded) {
+		t.Error("Downloaded file differs from the original")
+	}
+	ranges := map[string]bool{}
+	for _, r := range server.Requests()[requests:] {
+		ranges[r.Header.Get("Range")] = true
+	}
+	for i := meta.Merged; i < len(meta.Parts); i++ {
+		part := meta.Parts[i]
+		if meta.Paused[i] == 0 || meta.Paused[i] > part.Stop-part.Start {
+			continue
+		}
+		expected := fmt.Sprintf("bytes=%d-%d", part.Start+meta.Paused[i], part.Stop)
+		if !ranges[expected] {
+			t.Errorf("Expected part %d to be continued with %s, got %v", i+1, expected, ranges)
+		}
+	}
+}
+
+// syncFailStorage's part files can't be flushed
+type syncFailStorage struct {
+	*memStorage
+}
+
+type syncFailFile struct {
+	WriteSeekCloser
+}
+
+func (syncFailFile) Sync() error {
+	return errors.New("sync failed")
+}
+
+func (s syncFailStorage) Create(name string) (WriteSeekCloser, error) {
+	f, err := s.memStorage.Create(name)
+	if strings.Contains(name, ".part") {
+		return syncFailFile{f}, err
+	}
+	return f, err
+}
+
+func TestFlushOnPauseSyncFails(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	server := newTestServer(t, original, testServerOptions{
+		Latency:   10 * time.Millisecond,
+		ChunkSize: 16 * 1024,
+	})
+
+	storage := syncFailStorage{newMemStorage()}
+	config := &Config{
+		Url:          server.URL + "/book.pdf",
+		Concurrency:  4,
+		MinPartSize:  1,
+		OutFilename:  "book.pdf",
+		FlushOnPause: true,
+		Storage:      storage,
+	}
+
+	d, err := NewFromConfig(config)
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Download()
+	}()
+	for d.Stats().Downloaded <= int64(len(original)/3) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	d.Pause()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// the bytes of a part which wasn't flushed may not be on disk
+	meta, err := d.loadMetadata()
+	if err != nil || meta == nil || len(meta.Paused) != len(meta.Parts) {
+		t.Fatalf("Expected the paused offsets to be saved, got %v, %v", meta, err)
+	}
+	for _, stat := range d.PartStats() {
+		if !stat.Complete && meta.Paused[stat.Part-1] != 0 {
+			t.Errorf("Expected no paused offset for part %d, got %d", stat.Part, meta.Paused[stat.Part-1])
+		}
+	}
+
+	resumeConfig := *config
+	resumeConfig.Resume = true
+	d, err = NewFromConfig(&resumeConfig)
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, storage.files["book.pdf"]) {
+		t.Error("Downloaded file differs from the original")
+	}
+}
@@ -0,0 +1,47 @@
+package downloader
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Metrics receives measurements of the downloads for monitoring, e.g. to
+// be exported to Prometheus. The methods may be called from multiple
+// goroutines concurrently.
+type Metrics interface {
+	// bytes received from the server, as they arrive
+	ObserveBytes(n int64)
+	// a failed part is retried
+	IncRetry()
+	// a download has failed
+	IncFailure()
+	// a download has completed, with how long it took
+	ObserveDuration(d time.Duration)
+	// a download has completed, with its average speed in bytes per
+	// second, not counting the bytes downloaded before a resume
+	ObserveSpeed(bytesPerSecond float64)
+}
+
+// discards everything, used when Config.Metrics is nil
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveBytes(n int64)                {}
+func (nopMetrics) IncRetry()                           {}
+func (nopMetrics) IncFailure()                         {}
+func (nopMetrics) ObserveDuration(d time.Duration)     {}
+func (nopMetrics) ObserveSpeed(bytesPerSecond float64) {}
+
+// Reports the end of a download step which started at start
+func (d *Downloader) observeRun(start time.Time, err error) {
+	switch {
+	case err != nil:
+		d.metrics.IncFailure()
+	case d.isPaused() || d.skipped:
+	default:
+		duration := time.Since(start)
+		d.metrics.ObserveDuration(duration)
+		if duration > 0 {
+			d.metrics.ObserveSpeed(float64(atomic.LoadInt64(&d.runBytes)) / duration.Seconds())
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package downloader
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingMetrics struct {
+	mu        sync.Mutex
+	bytes     int64
+	retries   int
+	failures  int
+	durations []time.Duration
+	speeds    []float64
+}
+
+func (m *recordingMetrics) ObserveBytes(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytes += n
+}
+
+func (m *recordingMetrics) IncRetry() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries++
+}
+
+func (m *recordingMetrics) IncFailure() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures++
+}
+
+func (m *recordingMetrics) ObserveDuration(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.durations = append(m.durations, d)
+}
+
+func (m *recordingMetrics) ObserveSpeed(bytesPerSecond float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.speeds = append(m.speeds, bytesPerSecond)
+}
+
+func TestMetrics(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	// the first part fails once
+	server := newTestServer(t, original, testServerOptions{Failures: 1, FailAfter: 1024})
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	metrics := &recordingMetrics{}
+	d, err := NewFromConfig(&Config{
+		Url:          server.URL + "/book.pdf",
+		Concurrency:  2,
+		MinPartSize:  1,
+		OutFilename:  outFilename,
+		MaxRetries:   1,
+		RetryBackoff: time.Millisecond,
+		Metrics:      metrics,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the retry continues after the bytes of the failed request
+	if metrics.bytes != int64(len(original)) {
+		t.Errorf("Expected %d bytes to be observed, got %d", len(original), metrics.bytes)
+	}
+	if metrics.retries != 1 {
+		t.Errorf("Expected 1 retry, got %d", metrics.retries)
+	}
+	if metrics.failures != 0 {
+		t.Errorf("Expected no failures, got %d", metrics.failures)
+	}
+	if len(metrics.durations) != 1 || len(metrics.speeds) != 1 || metrics.speeds[0] <= 0 {
+		t.Errorf("Expected a duration and a speed, got %v and %v", metrics.durations, metrics.speeds)
+	}
+
+	// the server is gone
+	server.Close()
+	d, err = NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		OutFilename: outFilename,
+		OnExist:     OnExistOverwrite,
+		Metrics:     metrics,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err == nil {
+		t.Fatal("Expected the download to fail")
+	}
+	if metrics.failures != 1 || len(metrics.durations) != 1 {
+		t.Errorf("Expected only a failure to be observed, got %d failures and %d durations", metrics.failures, len(metrics.durations))
+	}
+}
@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMirrors(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	var mu sync.Mutex
+	served := make(map[string]int)
+	newServer := func(name string, content []byte) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				mu.Lock()
+				served[name]++
+				mu.Unlock()
+			}
+			http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(content))
+		}))
+	}
+
+	primary := newServer("primary", original)
+	defer primary.Close()
+	mirror := newServer("mirror", original)
+	defer mirror.Close()
+	badMirror := newServer("bad", original[:100])
+	defer badMirror.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         primary.URL + "/book.pdf",
+		Mirrors:     []string{mirror.URL + "/book.pdf"},
+		Concurrency: 4,
+		MinPartSize: 1,
+		OutFilename: outFilename,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	downloaded, err := ioutil.ReadFile(outFilename)
+	if err != nil {
+		t.Fatalf("Cannot read %s", outFilename)
+	}
+	if !bytes.Equal(original, downloaded) {
+		t.Error("Downloaded file is not the same as original file")
+	}
+	if served["primary"] != 2 || served["mirror"] != 2 {
+		t.Errorf("Expected parts to be spread over the mirrors, got %v", served)
+	}
+
+	// a mirror with a different size must be rejected
+	d, err = NewFromConfig(&Config{
+		Url:         primary.URL + "/book.pdf",
+		Mirrors:     []string{badMirror.URL + "/book.pdf"},
+		Concurrency: 4,
+		OutFilename: tempOutFilename(t),
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err == nil {
+		t.Error("Expected an error for a mirror with a different size")
+	}
+}
@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// credentials of a machine in a netrc file
+type netrcEntry struct {
+	Machine  string
+	Login    string
+	Password string
+}
+
+// Returns the path of the netrc file, $NETRC or ~/.netrc
+func netrcPath() (string, error) {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".netrc"), nil
+}
+
+// Parses a netrc file, the default entry has an empty Machine.
+// Macros are skipped, and so are the accounts.
+func parseNetrc(data []byte) ([]netrcEntry, error) {
+	var entries []netrcEntry
+	var entry *netrcEntry
+	inMacro := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			// a macro ends with an empty line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			token := fields[i]
+			switch token {
+			case "default":
+				entries = append(entries, netrcEntry{})
+				entry = &entries[len(entries)-1]
+				continue
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+				continue
+			}
+
+			if i+1 == len(fields) {
+				return nil, fmt.Errorf("%s has no value", token)
+			}
+			i++
+			value := fields[i]
+			if token == "machine" {
+				entries = append(entries, netrcEntry{Machine: value})
+				entry = &entries[len(entries)-1]
+				continue
+			}
+			if entry == nil {
+				return nil, fmt.Errorf("%s before any machine", token)
+			}
+			switch token {
+			case "login":
+				entry.Login = value
+			case "password":
+				entry.Password = value
+			case "account":
+				// not sent
+			default:
+				return nil, fmt.Errorf("unknown token %s", token)
+			}
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+// Returns the credentials of the netrc file for the host, the ones of
+// its machine or else the default ones. Nil if there are none or the
+// file doesn't exist.
+func netrcAuth(host string) (*url.Userinfo, error) {
+	path, err := netrcPath()
+	if err != nil {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries, err := parseNetrc(data)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid netrc file %s: %w", path, err)
+	}
+
+	var found *netrcEntry
+	for i, entry := range entries {
+		if entry.Machine == host {
+			found = &entries[i]
+			break
+		}
+		if entry.Machine == "" && found == nil {
+			found = &entries[i]
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+	return url.UserPassword(found.Login, found.Password), nil
+}
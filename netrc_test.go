@@ -0,0 +1,121 @@
+package downloader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseNetrc(t *testing.T) {
+	data := []byte(`# comment
+machine example.com login alice password secret
+machine other.org
+	login bob
+	password hunter2
+	account ignored
+
+macdef init
+cd /pub
+machine fake.com login x password y
+
+default login anonymous password guest
+`)
+	entries, err := parseNetrc(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []netrcEntry{
+		{Machine: "example.com", Login: "alice", Password: "secret"},
+		{Machine: "other.org", Login: "bob", Password: "hunter2"},
+		{Login: "anonymous", Password: "guest"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v, got %v", expected, entries)
+	}
+
+	for _, invalid := range []string{"machine", "login alice", "machine a login b foo c"} {
+		if _, err := parseNetrc([]byte(invalid)); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestNetrc(t *testing.T) {
+	data := []byte("content")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "alice" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.ServeContent(w, r, "file.txt", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "go_dl_netrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("NETRC", os.Getenv("NETRC"))
+
+	testCases := []struct {
+		Netrc    string
+		User     string
+		Password string
+		UseNetrc bool
+		Success  bool
+	}{
+		{Netrc: "machine 127.0.0.1 login alice password secret", UseNetrc: true, Success: true},
+		{Netrc: "machine example.com login bob password x\ndefault login alice password secret", UseNetrc: true, Success: true},
+		{Netrc: "machine 127.0.0.1 login alice password secret", UseNetrc: false, Success: false},
+		{Netrc: "machine example.com login alice password secret", UseNetrc: true, Success: false},
+		// explicit credentials win over the netrc file
+		{Netrc: "machine 127.0.0.1 login alice password wrong", User: "alice", Password: "secret", UseNetrc: true, Success: true},
+	}
+
+	for i, testCase := range testCases {
+		netrc := filepath.Join(dir, "netrc")
+		if err := ioutil.WriteFile(netrc, []byte(testCase.Netrc), 0600); err != nil {
+			t.Fatal(err)
+		}
+		os.Setenv("NETRC", netrc)
+
+		outFilename := tempOutFilename(t)
+		d, err := NewFromConfig(&Config{
+			Url:               server.URL + "/file.txt",
+			OutFilename:       outFilename,
+			BasicAuthUser:     testCase.User,
+			BasicAuthPassword: testCase.Password,
+			UseNetrc:          testCase.UseNetrc,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+		err = d.Download()
+		os.Remove(outFilename)
+		if testCase.Success && err != nil {
+			t.Errorf("%d: Expected the download to succeed, got %v", i, err)
+		}
+		if !testCase.Success && err == nil {
+			t.Errorf("%d: Expected the download to fail", i)
+		}
+	}
+
+	// a missing netrc file is not an error, an invalid one is
+	os.Setenv("NETRC", filepath.Join(dir, "missing"))
+	if _, err := NewFromConfig(&Config{Url: server.URL + "/file.txt", UseNetrc: true}); err != nil {
+		t.Errorf("Expected no error without a netrc file, got %v", err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "invalid"), []byte("login alice"), 0600)
+	os.Setenv("NETRC", filepath.Join(dir, "invalid"))
+	if _, err := NewFromConfig(&Config{Url: server.URL + "/file.txt", UseNetrc: true}); err == nil {
+		t.Error("Expected an error for an invalid netrc file")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !plan9
+// +build !plan9
+
+package downloader
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Reports whether err is caused by the process or the system running out
+// of file descriptors, which is transient with a high Concurrency
+func isTooManyOpenFiles(err error) bool {
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE)
+}
@@ -0,0 +1,9 @@
+//go:build plan9
+// +build plan9
+
+package downloader
+
+// Plan 9 has no error for running out of file descriptors
+func isTooManyOpenFiles(err error) bool {
+	return false
+}
@@ -0,0 +1,69 @@
+package downloader
+
+// PartStat is the progress of a single part of a concurrent download
+type PartStat struct {
+	// starts from 1
+	Part int
+	// byte range of the part, both ends are inclusive
+	Start int64
+	Stop  int64
+	// bytes downloaded so far, including the resumed ones
+	Downloaded int64
+	Complete   bool
+}
+
+// Returns the progress of every part, it's empty if the
+// download is not started or is not downloaded in parts
+func (d *Downloader) PartStats() []PartStat {
+	d.partsMu.Lock()
+	defer d.partsMu.Unlock()
+
+	return append([]PartStat(nil), d.partStats...)
+}
+
+// Resets the stats for a new run, the first merged parts are complete
+func (d *Downloader) initPartStats(parts []partRange, merged int) {
+	stats := make([]PartStat, len(parts))
+	for i, part := range parts {
+		stats[i] = PartStat{
+			Part:  i + 1,
+			Start: int64(part.Start),
+			Stop:  int64(part.Stop),
+		}
+		if i < merged {
+			stats[i].Downloaded = int64(part.Stop - part.Start + 1)
+			stats[i].Complete = true
+		}
+	}
+
+	d.partsMu.Lock()
+	d.partStats = stats
+	d.unsynced = make([]bool, len(parts))
+	d.partsMu.Unlock()
+}
+
+func (d *Downloader) addPartBytes(partNum int, n int64) {
+	d.partsMu.Lock()
+	d.partStats[partNum-1].Downloaded += n
+	d.partsMu.Unlock()
+}
+
+// Marks the part whose file couldn't be flushed on pause, the bytes
+// counted for it may not be on disk
+func (d *Downloader) markUnsynced(partNum int) {
+	d.partsMu.Lock()
+	d.unsynced[partNum-1] = true
+	d.partsMu.Unlock()
+}
+
+// Marks the part complete and calls OnPartComplete
+func (d *Downloader) completePart(partNum int) {
+	d.partsMu.Lock()
+	d.partStats[partNum-1].Complete = true
+	downloaded := d.partStats[partNum-1].Downloaded
+	d.partsMu.Unlock()
+
+	if d.config.OnPartComplete != nil {
+		d.config.OnPartComplete(partNum, downloaded)
+	}
+}
@@ -0,0 +1,213 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// how often the written bytes of each part are saved to the metadata
+const preallocSaveInterval = time.Second
+
+// preallocFile is a file of the storage the parts write to at their
+// offsets concurrently, which a Reader reads from while it's written
+type preallocFile interface {
+	WriteSeekCloser
+	io.WriterAt
+	io.ReaderAt
+}
+
+// offsetWriter writes to file sequentially starting at offset,
+// so the parts can share the file
+type offsetWriter struct {
+	file   io.WriterAt
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.file.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+// the file is shared, it's closed by the download
+func (w *offsetWriter) Close() error {
+	return nil
+}
+
+// Downloads the parts straight into a file of the full size at their
+// offsets, so there's nothing to merge. The file size doesn't tell what
+// is downloaded, so the written bytes of each part are kept in the metadata.
+func (d *Downloader) preallocatedDownload(contentSize int, parts []partRange) error {
+	if !d.config.Resume {
+		if err := d.checkDiskSpace(int64(contentSize), 0); err != nil {
+			return err
+		}
+	}
+
+	meta, err := d.prepareMetadata(contentSize, parts)
+	if err != nil {
+		return err
+	}
+	parts = meta.Parts
+
+	d.startProgress(int64(contentSize))
+
+	if err := d.adoptLegacyMergedFile(); err != nil {
+		return err
+	}
+	file, err := d.storage.Create(d.mergedFilename())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	f, ok := file.(preallocFile)
+	if !ok {
+		return errors.New("SinglePreallocatedFile needs a Storage whose files implement io.WriterAt and io.ReaderAt")
+	}
+	if err := d.storage.Truncate(d.mergedFilename(), int64(contentSize)); err != nil {
+		return err
+	}
+
+	d.setPrealloc(f, meta)
+	defer d.setPrealloc(nil, nil)
+
+	finished := false
+	// keep what is written for a resume, once the parts are stopped
+	defer func() {
+		if !finished {
+			d.savePreallocated(f, meta)
+		}
+	}()
+	partsDone, errCh := d.startParts(parts, 0)
+	defer d.stopParts(partsDone)
+
+	allDone := make(chan struct{})
+	go func() {
+		for _, done := range partsDone {
+			done.Wait()
+		}
+		close(allDone)
+	}()
+
+	hasher := &prefixHasher{file: f, sums: d.checksums()}
+	ticker := time.NewTicker(preallocSaveInterval)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case err := <-errCh:
+			return d.partsFailed(err, partsDone)
+		case <-allDone:
+			running = false
+		case <-ticker.C:
+			if err := d.savePreallocated(f, meta); err != nil {
+				return err
+			}
+			if err := hasher.advance(d.writtenPrefix()); err != nil {
+				return err
+			}
+		}
+	}
+
+	select {
+	case err := <-errCh:
+		return err
+	default:
+	}
+	if err := d.context.Err(); err != nil {
+		if d.isPaused() {
+			return nil
+		}
+		return err
+	}
+
+	if short := d.shortParts(); len(short) > 0 {
+		return fmt.Errorf("Downloaded file is incomplete (short parts: %s)", strings.Join(short, ", "))
+	}
+	if err := hasher.verify(int64(contentSize)); err != nil {
+		return err
+	}
+	// readers must not use the file once it's closed
+	d.setPrealloc(nil, nil)
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := d.moveFile(d.mergedFilename(), d.config.OutFilename); err != nil {
+		return err
+	}
+
+	finished = true
+	d.storage.Remove(d.metadataFilename())
+	return d.complete(nil)
+}
+
+// Sets the file the parts are written to, under partsMu so a Reader
+// never reads from it once it's being closed
+func (d *Downloader) setPrealloc(f preallocFile, meta *metadata) {
+	d.partsMu.Lock()
+	d.prealloc, d.preallocMeta = f, meta
+	d.partsMu.Unlock()
+}
+
+// Flushes the file and records how much of each part is written. The
+// counts are taken before the sync, so they never claim unwritten bytes.
+func (d *Downloader) savePreallocated(f preallocFile, meta *metadata) error {
+	stats := d.PartStats()
+	if err := syncFile(f); err != nil {
+		return err
+	}
+
+	for _, stat := range stats {
+		meta.Written[stat.Part-1] = int(stat.Downloaded)
+	}
+	return d.saveMetadata(meta)
+}
+
+// prefixHasher hashes the preallocated file in order while it's being
+// downloaded, so it isn't read again once it's complete. The parts are
+// written out of order, only the bytes up to the first unfinished part
+// can be hashed, so a slow first part leaves most of the work to the end.
+// The bytes are read back while they're likely still in the page cache.
+type prefixHasher struct {
+	file   io.ReaderAt
+	sums   []*checksum
+	hashed int64
+}
+
+// Hashes the file up to upTo, the bytes before it must be written
+func (h *prefixHasher) advance(upTo int64) error {
+	if len(h.sums) == 0 || upTo <= h.hashed {
+		return nil
+	}
+
+	n, err := io.Copy(checksumWriter(ioutil.Discard, h.sums), io.NewSectionReader(h.file, h.hashed, upTo-h.hashed))
+	h.hashed += n
+	return err
+}
+
+// Hashes the rest of the file of the given size and compares it against the checksums
+func (h *prefixHasher) verify(size int64) error {
+	if err := h.advance(size); err != nil {
+		return err
+	}
+	return verifyChecksums(h.sums)
+}
+
+// Returns the number of leading bytes of the file which are written,
+// i.e. the parts before the first unfinished one and what it has written
+func (d *Downloader) writtenPrefix() int64 {
+	var prefix int64
+	for _, stat := range d.PartStats() {
+		if stat.Start != prefix {
+			break
+		}
+		prefix += stat.Downloaded
+		if !stat.Complete {
+			break
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,262 @@
+package downloader
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSinglePreallocatedFile(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	sum := sha256.Sum256(original)
+
+	outputDir, err := ioutil.TempDir("", "go_dl_prealloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	var mu sync.Mutex
+	partFiles := 0
+	d, err := NewFromConfig(&Config{
+		Url:                    server.URL + "/book.pdf",
+		Concurrency:            4,
+		MinPartSize:            1,
+		OutputDir:              outputDir,
+		SinglePreallocatedFile: true,
+		ExpectedSHA256:         hex.EncodeToString(sum[:]),
+		OnProgress: func(downloaded, total int64) {
+			matches, _ := filepath.Glob(filepath.Join(outputDir, "*.part*"))
+			mu.Lock()
+			partFiles += len(matches)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	if partFiles != 0 {
+		t.Error("Expected no part files to be created")
+	}
+	downloaded, err := ioutil.ReadFile(filepath.Join(outputDir, "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, downloaded) {
+		t.Error("Downloaded file is not the same as original file")
+	}
+}
+
+func TestResumeSinglePreallocatedFile(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	parts := splitRanges(len(original), 3)
+
+	// at first the second part breaks off in the middle
+	var mu sync.Mutex
+	healthy := false
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, stop int
+		_, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &stop)
+		mu.Lock()
+		ok := healthy
+		if err == nil {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		mu.Unlock()
+
+		if err == nil && start == parts[1].Start && !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, stop, len(original)))
+			w.Header().Set("Content-Length", strconv.Itoa(stop-start+1))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(original[start : start+(stop-start)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(original))
+	}))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	config := Config{
+		Url:                    server.URL + "/book.pdf",
+		Concurrency:            3,
+		MinPartSize:            1,
+		OutFilename:            outFilename,
+		SinglePreallocatedFile: true,
+	}
+	d, err := NewFromConfig(&config)
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err == nil {
+		t.Fatal("Expected the first download to fail")
+	}
+
+	mu.Lock()
+	healthy = true
+	ranges = nil
+	mu.Unlock()
+
+	config.Resume = true
+	d, err = NewFromConfig(&config)
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	downloaded, err := ioutil.ReadFile(outFilename)
+	if err != nil {
+		t.Fatalf("Cannot read %s", outFilename)
+	}
+	if !bytes.Equal(original, downloaded) {
+		t.Error("Downloaded file is not the same as original file")
+	}
+
+	// only the missing half is requested again
+	for _, rangeHeader := range ranges {
+		if rangeHeader == fmt.Sprintf("bytes=%d-%d", parts[1].Start, parts[1].Stop) {
+			t.Errorf("Expected part 2 to be resumed, got %s", rangeHeader)
+		}
+	}
+}
+
+func TestWorkStealing(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	chunks := (len(original) + 256*1024 - 1) / (256 * 1024)
+	firstChunk := fmt.Sprintf("bytes=0-%d", splitRanges(len(original), chunks)[0].Stop)
+
+	// the first chunk is slow, the other connections take the rest
+	var mu sync.Mutex
+	active, maxActive, requests := 0, 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			active++
+			requests++
+			if active > maxActive {
+				maxActive = active
+			}
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				active--
+				mu.Unlock()
+			}()
+			if r.Header.Get("Range") == firstChunk {
+				time.Sleep(300 * time.Millisecond)
+			}
+		}
+		http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(original))
+	}))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:          server.URL + "/book.pdf",
+		Concurrency:  3,
+		OutFilename:  outFilename,
+		WorkStealing: true,
+		ChunkSize:    256 * 1024,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	downloaded, err := ioutil.ReadFile(outFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, downloaded) {
+		t.Error("Downloaded file differs from the original")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != chunks {
+		t.Errorf("Expected a request per chunk (%d), got %d", chunks, requests)
+	}
+	if maxActive > 3 {
+		t.Errorf("Expected at most 3 connections at a time, got %d", maxActive)
+	}
+}
+
+func TestPrefixHasher(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	sum := sha256.Sum256(data)
+
+	f, err := ioutil.TempFile("", "go_dl_hasher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Downloader{config: &Config{}}
+	d.initPartStats(splitRanges(len(data), 4), 0)
+	hasher := &prefixHasher{file: f, sums: []*checksum{{algorithm: "sha256", expected: hex.EncodeToString(sum[:]), hash: sha256.New()}}}
+
+	// the second part is ahead of the first one
+	d.addPartBytes(1, 3)
+	d.addPartBytes(2, 5)
+	d.completePart(2)
+	if err := hasher.advance(d.writtenPrefix()); err != nil {
+		t.Fatal(err)
+	}
+	if hasher.hashed != 3 {
+		t.Errorf("Expected 3 bytes to be hashed, got %d", hasher.hashed)
+	}
+
+	d.addPartBytes(1, 2)
+	d.completePart(1)
+	d.addPartBytes(3, 1)
+	if err := hasher.advance(d.writtenPrefix()); err != nil {
+		t.Fatal(err)
+	}
+	if hasher.hashed != 11 {
+		t.Errorf("Expected 11 bytes to be hashed, got %d", hasher.hashed)
+	}
+
+	if err := hasher.verify(int64(len(data))); err != nil {
+		t.Errorf("Expected the checksum to match, got %v", err)
+	}
+}
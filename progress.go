@@ -0,0 +1,345 @@
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// minimum time between two updates of the bar and OnProgress calls
+// if ProgressInterval is zero
+const progressReportInterval = 100 * time.Millisecond
+
+// minimum time between two lines of ProgressFormatJSON
+const jsonProgressInterval = time.Second
+
+const (
+	// the speed is averaged over this window
+	speedWindow = 5 * time.Second
+	// minimum time between two speed samples
+	speedSampleInterval = 200 * time.Millisecond
+)
+
+// Stats is a snapshot of the download's progress
+type Stats struct {
+	// false until the download starts receiving the file, the other
+	// fields are zero until then
+	Started bool
+	// bytes downloaded so far, including the resumed ones
+	Downloaded int64
+	// size of the file, -1 if unknown
+	Total int64
+	// bytes per second, averaged over the last few seconds
+	Speed float64
+	// estimated time remaining, zero if unknown
+	ETA time.Duration
+}
+
+// number of downloaded bytes at a point in time
+type speedSample struct {
+	at         time.Time
+	downloaded int64
+}
+
+// progress counts the bytes written by all parts and reports
+// them to the progress bar and the OnProgress callback
+type progress struct {
+	mu         sync.Mutex
+	downloaded int64
+	total      int64
+	samples    []speedSample
+
+	// minimum time between two updates of the bar and OnProgress calls,
+	// the bytes read meanwhile are added to the bar at once
+	interval   time.Duration
+	lastReport time.Time
+	reported   int64
+	lastBar    time.Time
+	barPending int64
+
+	// optional
+	bar        *progressbar.ProgressBar
+	onProgress func(downloaded, total int64)
+	// serializes the OnProgress calls, which skip the counts below the
+	// last one delivered, so a late call never follows a newer one.
+	// It's not held with mu, the callback may ask for the Stats.
+	callbackMu sync.Mutex
+	delivered  int64
+	// ProgressFormatJSON lines are written to it
+	jsonWriter io.Writer
+	lastJSON   time.Time
+	// the bar is also drawn when a message is logged, which
+	// mustn't happen in the middle of an update
+	barMu sync.Mutex
+}
+
+// Replaces the download's progress with a new one of total bytes,
+// Stats may read it from another goroutine meanwhile
+func (d *Downloader) startProgress(total int64) {
+	p := d.newProgress(total)
+	d.stateMu.Lock()
+	d.progress = p
+	d.stateMu.Unlock()
+}
+
+func (d *Downloader) currentProgress() *progress {
+	d.stateMu.Lock()
+	defer d.stateMu.Unlock()
+	return d.progress
+}
+
+// Creates the progress of a download of total bytes as configured
+func (d *Downloader) newProgress(total int64) *progress {
+	w := d.config.ProgressWriter
+	if w == nil {
+		w = os.Stderr
+	}
+
+	var p *progress
+	switch {
+	case d.config.ProgressFormat == ProgressFormatJSON:
+		p = newProgress(total, nil, d.config.OnProgress)
+		p.jsonWriter = w
+	case d.config.ProgressFormat == ProgressFormatBar && d.config.ShowProgressBar:
+		p = newProgress(total, w, d.config.OnProgress)
+	default:
+		p = newProgress(total, nil, d.config.OnProgress)
+	}
+	if d.config.ProgressInterval > 0 {
+		p.interval = d.config.ProgressInterval
+	}
+
+	return p
+}
+
+// total is -1 if the size is unknown, the bar becomes a spinner then.
+// The bar is rendered to barWriter, there's no bar if it's nil.
+func newProgress(total int64, barWriter io.Writer, onProgress func(downloaded, total int64)) *progress {
+	p := &progress{
+		total:      total,
+		onProgress: onProgress,
+		samples:    []speedSample{{at: time.Now()}},
+		interval:   progressReportInterval,
+		delivered:  -1,
+	}
+	if barWriter != nil {
+		p.bar = newProgressBar(total, barWriter)
+	}
+
+	return p
+}
+
+// Same as progressbar.DefaultBytes, but renders to w
+func newProgressBar(total int64, w io.Writer) *progressbar.ProgressBar {
+	bar := progressbar.NewOptions64(
+		total,
+		progressbar.OptionSetDescription("downloading"),
+		progressbar.OptionSetWriter(w),
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionSetWidth(10),
+		progressbar.OptionThrottle(65*time.Millisecond),
+		progressbar.OptionShowCount(),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprint(w, "\n")
+		}),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionFullWidth(),
+	)
+	bar.RenderBlank()
+	return bar
+}
+
+// Runs fn with the bar cleared from the terminal and draws it again
+// afterwards, so what fn prints doesn't get mixed with the bar
+func (p *progress) aboveBar(fn func()) {
+	p.barMu.Lock()
+	defer p.barMu.Unlock()
+
+	if p.bar == nil || p.bar.IsFinished() {
+		fn()
+		return
+	}
+
+	p.bar.Clear()
+	fn()
+	p.bar.RenderBlank()
+}
+
+func (p *progress) addToBar(n int64) {
+	if p.bar == nil || n == 0 {
+		return
+	}
+
+	p.barMu.Lock()
+	p.bar.Add64(n)
+	p.barMu.Unlock()
+}
+
+// Counts the bytes of a read, to be used as the callback of a ProgressReader
+func (d *Downloader) countProgress(n, total int64) {
+	d.progress.add(n)
+	atomic.AddInt64(&d.runBytes, n)
+	d.metrics.ObserveBytes(n)
+}
+
+// Counts bytes that were downloaded in a previous run,
+// they don't count towards the speed
+func (p *progress) addExisting(n int64) {
+	p.mu.Lock()
+	p.downloaded += n
+	for i := range p.samples {
+		p.samples[i].downloaded += n
+	}
+	p.mu.Unlock()
+
+	p.addToBar(n)
+}
+
+func (p *progress) add(n int64) {
+	p.mu.Lock()
+	p.downloaded += n
+	p.sample()
+	downloaded, total := p.downloaded, p.total
+	now := time.Now()
+	// throttle the bar and the callback but never miss the last update
+	var barBytes int64
+	if p.bar != nil {
+		p.barPending += n
+		if now.Sub(p.lastBar) >= p.interval || downloaded == total {
+			barBytes, p.barPending = p.barPending, 0
+			p.lastBar = now
+		}
+	}
+	report := p.onProgress != nil &&
+		(now.Sub(p.lastReport) >= p.interval || downloaded == total)
+	if report {
+		p.lastReport, p.reported = now, downloaded
+	}
+	writeJSON := p.jsonWriter != nil &&
+		(now.Sub(p.lastJSON) >= jsonProgressInterval || downloaded == total)
+	if writeJSON {
+		p.lastJSON = now
+	}
+	p.mu.Unlock()
+
+	p.addToBar(barBytes)
+	if report {
+		p.report(downloaded, total)
+	}
+	if writeJSON {
+		p.writeJSON()
+	}
+}
+
+// Reports the bytes held back by the throttling, once the download
+// has stopped, e.g. the end of a file of unknown size
+func (p *progress) flush() {
+	p.mu.Lock()
+	barBytes := p.barPending
+	p.barPending = 0
+	downloaded, total := p.downloaded, p.total
+	report := p.onProgress != nil && downloaded != p.reported
+	if report {
+		p.lastReport, p.reported = time.Now(), downloaded
+	}
+	p.mu.Unlock()
+
+	p.addToBar(barBytes)
+	if report {
+		p.report(downloaded, total)
+	}
+}
+
+// Calls OnProgress unless a larger count has been delivered already,
+// the parts decide to report concurrently and may arrive out of order
+func (p *progress) report(downloaded, total int64) {
+	p.callbackMu.Lock()
+	defer p.callbackMu.Unlock()
+
+	if downloaded <= p.delivered {
+		return
+	}
+	p.delivered = downloaded
+	p.onProgress(downloaded, total)
+}
+
+// a line of ProgressFormatJSON
+type jsonProgress struct {
+	Downloaded int64   `json:"downloaded"`
+	Total      int64   `json:"total"`
+	Speed      float64 `json:"speed"`
+	Percent    float64 `json:"percent"`
+}
+
+// Writes the current progress as a line of JSON. The line is written at
+// once and under barMu, so concurrent parts never interleave their lines.
+func (p *progress) writeJSON() {
+	p.barMu.Lock()
+	defer p.barMu.Unlock()
+
+	s := p.stats()
+	line := jsonProgress{Downloaded: s.Downloaded, Total: s.Total, Speed: s.Speed}
+	if s.Total > 0 {
+		line.Percent = float64(s.Downloaded) * 100 / float64(s.Total)
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	p.jsonWriter.Write(append(data, '\n'))
+}
+
+func (p *progress) state() progressbar.State {
+	if p.bar != nil {
+		p.barMu.Lock()
+		defer p.barMu.Unlock()
+		return p.bar.State()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := progressbar.State{CurrentBytes: float64(p.downloaded)}
+	if p.total > 0 {
+		s.CurrentPercent = float64(p.downloaded) / float64(p.total)
+	}
+	return s
+}
+
+// Records a speed sample, the caller must hold the lock
+func (p *progress) sample() {
+	now := time.Now()
+	if now.Sub(p.samples[len(p.samples)-1].at) < speedSampleInterval {
+		return
+	}
+
+	p.samples = append(p.samples, speedSample{at: now, downloaded: p.downloaded})
+	// keep one sample older than the window to measure the whole window
+	for len(p.samples) > 2 && now.Sub(p.samples[1].at) > speedWindow {
+		p.samples = p.samples[1:]
+	}
+}
+
+func (p *progress) stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := Stats{Started: true, Downloaded: p.downloaded, Total: p.total}
+
+	oldest := p.samples[0]
+	if elapsed := time.Since(oldest.at).Seconds(); elapsed > 0 {
+		s.Speed = float64(p.downloaded-oldest.downloaded) / elapsed
+	}
+	if s.Speed > 0 && p.total > 0 {
+		remaining := float64(p.total - p.downloaded)
+		s.ETA = time.Duration(remaining / s.Speed * float64(time.Second))
+	}
+
+	return s
+}
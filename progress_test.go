@@ -0,0 +1,359 @@
+package downloader
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOnProgress(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	var mu sync.Mutex
+	var calls int
+	var lastDownloaded, lastTotal int64
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 4,
+		OutFilename: outFilename,
+		OnProgress: func(downloaded, total int64) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			lastDownloaded, lastTotal = downloaded, total
+		},
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(outFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls == 0 {
+		t.Fatal("Expected OnProgress to be called")
+	}
+	if lastDownloaded != info.Size() || lastTotal != info.Size() {
+		t.Errorf("Expected last progress to be %d/%d, got %d/%d", info.Size(), info.Size(), lastDownloaded, lastTotal)
+	}
+}
+
+func TestProgressStats(t *testing.T) {
+	p := newProgress(1000, nil, nil)
+	p.addExisting(200)
+
+	// simulate a steady download of 100 bytes every 50ms
+	for i := 0; i < 6; i++ {
+		time.Sleep(50 * time.Millisecond)
+		p.add(100)
+	}
+
+	stats := p.stats()
+	if stats.Downloaded != 800 {
+		t.Errorf("Expected 800 downloaded bytes, got %d", stats.Downloaded)
+	}
+	if stats.Total != 1000 {
+		t.Errorf("Expected total to be 1000, got %d", stats.Total)
+	}
+	// resumed bytes must not inflate the speed, ~2000 bytes/s is expected
+	if stats.Speed < 1000 || stats.Speed > 3000 {
+		t.Errorf("Expected speed around 2000 bytes/s, got %f", stats.Speed)
+	}
+	if stats.ETA <= 0 || stats.ETA > time.Second {
+		t.Errorf("Expected ETA around 100ms, got %v", stats.ETA)
+	}
+}
+
+func TestPartStats(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 3,
+		MinPartSize: 1,
+		OutFilename: outFilename,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if len(d.PartStats()) != 0 {
+		t.Error("Expected no part stats before the download starts")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := d.PartStats()
+	if len(stats) != 3 {
+		t.Fatalf("Expected 3 parts, got %d", len(stats))
+	}
+	for _, stat := range stats {
+		if !stat.Complete {
+			t.Errorf("Expected part %d to be complete", stat.Part)
+		}
+		if stat.Downloaded != stat.Stop-stat.Start+1 {
+			t.Errorf("Expected part %d to have %d bytes, got %d", stat.Part, stat.Stop-stat.Start+1, stat.Downloaded)
+		}
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	var bar bytes.Buffer
+	logger := &recordingLogger{}
+	d, err := NewFromConfig(&Config{
+		Url:             server.URL + "/book.pdf",
+		Concurrency:     2,
+		MinPartSize:     1,
+		OutFilename:     outFilename,
+		ShowProgressBar: true,
+		ProgressWriter:  &bar,
+		Logger:          logger,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(bar.String(), "downloading") {
+		t.Errorf("Expected the progress bar to be rendered to ProgressWriter, got %q", bar.String())
+	}
+	for _, message := range logger.messages {
+		if strings.Contains(message, "downloading") {
+			t.Errorf("Expected the progress bar not to be in the log, got %q", message)
+		}
+	}
+}
+
+func TestProgressFormatJSON(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	var out bytes.Buffer
+	d, err := NewFromConfig(&Config{
+		Url:            server.URL + "/book.pdf",
+		Concurrency:    4,
+		MinPartSize:    1,
+		OutFilename:    outFilename,
+		ProgressWriter: &out,
+		ProgressFormat: ProgressFormatJSON,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	var last jsonProgress
+	for _, line := range lines {
+		if err := json.Unmarshal([]byte(line), &last); err != nil {
+			t.Fatalf("Expected a JSON object per line, got %q: %v", line, err)
+		}
+	}
+	if last.Total != 2142798 || last.Downloaded != last.Total {
+		t.Errorf("Expected the last line to report %d of %d bytes, got %d of %d", 2142798, 2142798, last.Downloaded, last.Total)
+	}
+	if last.Percent != 100 {
+		t.Errorf("Expected the last line to report 100 percent, got %v", last.Percent)
+	}
+}
+
+func TestCompletionCallbacks(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	var mu sync.Mutex
+	partBytes := make(map[int]int64)
+	var completedPath string
+	var completedTotal int64
+
+	var d *Downloader
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 3,
+		MinPartSize: 1,
+		OutFilename: outFilename,
+		OnPartComplete: func(partNum int, bytes int64) {
+			// calling back into the downloader must not deadlock
+			d.PartStats()
+			mu.Lock()
+			defer mu.Unlock()
+			partBytes[partNum] = bytes
+		},
+		OnComplete: func(path string, total int64) {
+			d.Stats()
+			completedPath, completedTotal = path, total
+		},
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(outFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(partBytes) != 3 {
+		t.Errorf("Expected OnPartComplete to be called for 3 parts, got %d", len(partBytes))
+	}
+	var sum int64
+	for _, bytes := range partBytes {
+		sum += bytes
+	}
+	if sum != info.Size() {
+		t.Errorf("Expected the parts to add up to %d bytes, got %d", info.Size(), sum)
+	}
+	if completedPath != d.OutputPath() || completedTotal != info.Size() {
+		t.Errorf("Expected OnComplete with %s and %d, got %s and %d", d.OutputPath(), info.Size(), completedPath, completedTotal)
+	}
+}
+
+func TestStarted(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	var d *Downloader
+	startedWhileDownloading := true
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		OutFilename: outFilename,
+		OnProgress: func(downloaded, total int64) {
+			if !d.Started() || !d.Stats().Started {
+				startedWhileDownloading = false
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+
+	if d.Started() || d.Stats().Started {
+		t.Error("Expected the download not to be started before Download")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+	if !startedWhileDownloading {
+		t.Error("Expected the download to be started while receiving the file")
+	}
+	if !d.Started() || !d.Stats().Started {
+		t.Error("Expected the download to be started after Download")
+	}
+
+	if err := d.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if d.Started() {
+		t.Error("Expected the download not to be started after Reset")
+	}
+}
+
+func TestProgressInterval(t *testing.T) {
+	var calls int
+	var lastDownloaded int64
+	p := newProgress(-1, ioutil.Discard, func(downloaded, total int64) {
+		calls++
+		lastDownloaded = downloaded
+	})
+	p.interval = time.Hour
+
+	// tiny reads, as with a small CopyBufferSize
+	for i := 0; i < 1000; i++ {
+		p.add(1)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 OnProgress call within the interval, got %d", calls)
+	}
+	if current := p.bar.State().CurrentBytes; current != 1 {
+		t.Errorf("Expected the bar to be updated once, got %v bytes", current)
+	}
+
+	// the size is unknown, the end is reported once the download stops
+	p.flush()
+	if calls != 2 || lastDownloaded != 1000 {
+		t.Errorf("Expected a last OnProgress call with 1000 bytes, got %d calls with %d", calls, lastDownloaded)
+	}
+	if current := p.bar.State().CurrentBytes; current != 1000 {
+		t.Errorf("Expected the bar to show 1000 bytes, got %v", current)
+	}
+	p.flush()
+	if calls != 2 {
+		t.Errorf("Expected no OnProgress call without new bytes, got %d calls", calls)
+	}
+}
+
+func TestProgressCallbackOrder(t *testing.T) {
+	const parts, reads = 8, 1000
+	var mu sync.Mutex
+	var calls []int64
+	p := newProgress(parts*reads, nil, func(downloaded, total int64) {
+		mu.Lock()
+		calls = append(calls, downloaded)
+		mu.Unlock()
+	})
+	p.interval = 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < parts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < reads; j++ {
+				p.add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	p.flush()
+
+	// the parts race to report, a late count must not follow a newer one
+	for i := 1; i < len(calls); i++ {
+		if calls[i] <= calls[i-1] {
+			t.Fatalf("Expected increasing OnProgress counts, got %d after %d", calls[i], calls[i-1])
+		}
+	}
+	if last := calls[len(calls)-1]; last != parts*reads {
+		t.Errorf("Expected the last OnProgress call with %d bytes, got %d", parts*reads, last)
+	}
+}
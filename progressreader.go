@@ -0,0 +1,44 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+)
+
+// ProgressReader counts the bytes read from an io.Reader and reports
+// each read to a callback. Once its context is done, reads fail with
+// the context's error, so a copy from it stops even if the underlying
+// reader doesn't know about the context.
+type ProgressReader struct {
+	ctx    context.Context
+	reader io.Reader
+	// called after every read with the bytes of that read and the bytes read so far
+	onRead func(n, total int64)
+	total  int64
+}
+
+// Wraps r, onRead may be nil
+func NewProgressReader(ctx context.Context, r io.Reader, onRead func(n, total int64)) *ProgressReader {
+	return &ProgressReader{ctx: ctx, reader: r, onRead: onRead}
+}
+
+func (r *ProgressReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		total := atomic.AddInt64(&r.total, int64(n))
+		if r.onRead != nil {
+			r.onRead(int64(n), total)
+		}
+	}
+	return n, err
+}
+
+// Returns the number of bytes read so far
+func (r *ProgressReader) BytesRead() int64 {
+	return atomic.LoadInt64(&r.total)
+}
@@ -0,0 +1,60 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestProgressReader(t *testing.T) {
+	data := strings.Repeat("0123456789", 1000)
+
+	var calls, sum, last int64
+	r := NewProgressReader(context.Background(), strings.NewReader(data), func(n, total int64) {
+		calls++
+		sum += n
+		if total != sum {
+			t.Errorf("Expected total %d, got %d", sum, total)
+		}
+		last = total
+	})
+
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, []byte(data)) {
+		t.Error("Read data differs from the source")
+	}
+	if calls == 0 {
+		t.Error("Expected OnRead to be called")
+	}
+	if last != int64(len(data)) || r.BytesRead() != int64(len(data)) {
+		t.Errorf("Expected %d bytes read, got %d (BytesRead %d)", len(data), last, r.BytesRead())
+	}
+}
+
+func TestProgressReaderCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewProgressReader(ctx, strings.NewReader(strings.Repeat("x", 1000)), nil)
+
+	buf := make([]byte, 100)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	n, err := r.Read(buf)
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if n != 0 {
+		t.Errorf("Expected no bytes after cancel, got %d", n)
+	}
+	if r.BytesRead() != 100 {
+		t.Errorf("Expected 100 bytes read, got %d", r.BytesRead())
+	}
+}
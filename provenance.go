@@ -0,0 +1,36 @@
+package downloader
+
+import (
+	"errors"
+	"time"
+)
+
+// extended attributes recording where and when the file was downloaded
+const (
+	xattrSourceURL    = "user.go-dl.source-url"
+	xattrDownloadedAt = "user.go-dl.downloaded-at"
+)
+
+// Returned by setXattr when the platform or filesystem has no extended attributes
+var errXattrUnsupported = errors.New("extended attributes are not supported")
+
+// Tags the output file with its source url, without credentials, and the
+// download time. Failing to do so doesn't fail the download, it's logged.
+// Only local files have extended attributes.
+func (d *Downloader) writeProvenance() {
+	path := d.OutputPath()
+	if !d.isLocalStorage() || d.isSpecialFile(path) {
+		return
+	}
+
+	err := setXattr(path, xattrSourceURL, redactURL(d.config.Url))
+	if err == nil {
+		err = setXattr(path, xattrDownloadedAt, time.Now().UTC().Format(time.RFC3339))
+	}
+	switch {
+	case errors.Is(err, errXattrUnsupported):
+		d.logf("Extended attributes aren't supported for %s, its provenance isn't recorded", path)
+	case err != nil:
+		d.logf("Cannot record the provenance of %s: %v", path, err)
+	}
+}
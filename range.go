@@ -0,0 +1,119 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Returned when RangeStart or RangeEnd is set but the server doesn't
+// support range requests, so only the whole file could be downloaded
+var ErrRangesUnsupported = errors.New("Server doesn't support ranges, the requested sub-range can't be downloaded")
+
+// Whether only a sub-range of the file is downloaded
+func (d *Downloader) rangeRequested() bool {
+	return d.config.RangeStart > 0 || d.config.RangeEnd > 0
+}
+
+// Returns the size of the requested sub-range of a file of the given
+// size, which is -1 if unknown. The sub-range is cut at the end of the file.
+func (d *Downloader) subRangeSize(size int64) (int, error) {
+	start, end := d.config.RangeStart, d.config.RangeEnd
+	if start < 0 || end < 0 {
+		return 0, fmt.Errorf("Invalid range: %d-%d", start, end)
+	}
+	if end == 0 || (size > 0 && end >= size) {
+		if size <= 0 {
+			return 0, errors.New("File size is unknown, RangeEnd must be set to download a sub-range")
+		}
+		end = size - 1
+	}
+	if start > end {
+		return 0, fmt.Errorf("%w: bytes=%d-%d", ErrRangeNotSatisfiable, start, end)
+	}
+
+	return int(end - start + 1), nil
+}
+
+// Like probe, when a sub-range is requested. The sub-range is always
+// downloaded in parts, it's an error if the server doesn't support it.
+func (d *Downloader) probeSubRange(info *RemoteInfo) (int, error) {
+	if !info.AcceptRanges {
+		return -1, ErrRangesUnsupported
+	}
+	contentSize, err := d.subRangeSize(info.Size)
+	if err != nil {
+		return -1, err
+	}
+	if err := d.checkMaxSize(int64(contentSize)); err != nil {
+		return -1, err
+	}
+	if info.Size > 0 {
+		if err := d.verifyMirrors(info.Size); err != nil {
+			return -1, err
+		}
+	}
+
+	// the output file has the size of the sub-range
+	d.remoteSize = int64(contentSize)
+	d.contentSize = contentSize
+	return contentSize, nil
+}
+
+// contentRange is a parsed Content-Range header, e.g. bytes 0-499/1234
+type contentRange struct {
+	// the first and last byte of the response, both -1 for an
+	// unsatisfied range (bytes */1234)
+	Start int64
+	Stop  int64
+	// the size of the whole file, -1 if unknown (bytes 0-499/*)
+	Total int64
+}
+
+// Parses a Content-Range header of the forms bytes a-b/total,
+// bytes a-b/* and bytes */total
+func parseContentRange(header string) (contentRange, error) {
+	invalid := fmt.Errorf("invalid Content-Range %q", header)
+	spec := strings.TrimSpace(header)
+	if !strings.HasPrefix(spec, "bytes ") {
+		return contentRange{}, invalid
+	}
+	spec = strings.TrimSpace(strings.TrimPrefix(spec, "bytes "))
+	index := strings.LastIndex(spec, "/")
+	if index == -1 {
+		return contentRange{}, invalid
+	}
+	rangeSpec, totalSpec := spec[:index], spec[index+1:]
+
+	cr := contentRange{Start: -1, Stop: -1, Total: -1}
+	if totalSpec != "*" {
+		total, err := strconv.ParseInt(totalSpec, 10, 64)
+		if err != nil || total < 0 {
+			return contentRange{}, invalid
+		}
+		cr.Total = total
+	}
+	if rangeSpec == "*" {
+		if cr.Total < 0 {
+			// bytes */* says nothing
+			return contentRange{}, invalid
+		}
+		return cr, nil
+	}
+
+	bounds := strings.SplitN(rangeSpec, "-", 2)
+	if len(bounds) != 2 {
+		return contentRange{}, invalid
+	}
+	start, err := strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil {
+		return contentRange{}, invalid
+	}
+	stop, err := strconv.ParseInt(bounds[1], 10, 64)
+	if err != nil || start < 0 || stop < start || (cr.Total >= 0 && stop >= cr.Total) {
+		return contentRange{}, invalid
+	}
+	cr.Start, cr.Stop = start, stop
+	return cr, nil
+}
@@ -0,0 +1,211 @@
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubRange(t *testing.T) {
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+
+	testCases := []struct {
+		RangeStart int64
+		RangeEnd   int64
+		Expected   string
+	}{
+		{RangeStart: 10, RangeEnd: 19, Expected: "abcdefghij"},
+		{RangeStart: 0, RangeEnd: 3, Expected: "0123"},
+		// to the end of the file
+		{RangeStart: 30, RangeEnd: 0, Expected: "uvwxyz"},
+		// cut at the end of the file
+		{RangeStart: 33, RangeEnd: 100, Expected: "xyz"},
+	}
+
+	for _, testCase := range testCases {
+		server := newTestServer(t, data, testServerOptions{})
+		outFilename := tempOutFilename(t)
+
+		d, err := NewFromConfig(&Config{
+			Url:         server.URL + "/file.bin",
+			Concurrency: 3,
+			MinPartSize: 1,
+			OutFilename: outFilename,
+			RangeStart:  testCase.RangeStart,
+			RangeEnd:    testCase.RangeEnd,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+		if err := d.Download(); err != nil {
+			t.Fatalf("Expected range %d-%d to be downloaded, got %v", testCase.RangeStart, testCase.RangeEnd, err)
+		}
+
+		content, _ := ioutil.ReadFile(outFilename)
+		if string(content) != testCase.Expected {
+			t.Errorf("Expected %q, got %q", testCase.Expected, content)
+		}
+		gets := 0
+		for _, req := range server.Requests() {
+			if req.Method == http.MethodGet {
+				gets++
+			}
+		}
+		if gets != 3 {
+			t.Errorf("Expected 3 range requests, got %d", gets)
+		}
+		os.Remove(outFilename)
+	}
+}
+
+func TestSubRangeUnsupported(t *testing.T) {
+	server := newTestServer(t, []byte("0123456789"), testServerOptions{NoRanges: true})
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/file.bin",
+		Concurrency: 2,
+		OutFilename: outFilename,
+		RangeStart:  2,
+		RangeEnd:    5,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+
+	if err := d.Download(); !errors.Is(err, ErrRangesUnsupported) {
+		t.Errorf("Expected ErrRangesUnsupported, got %v", err)
+	}
+	if _, err := os.Stat(outFilename); !os.IsNotExist(err) {
+		t.Error("Expected no output file")
+	}
+}
+
+func TestSubRangeNotSatisfiable(t *testing.T) {
+	server := newTestServer(t, []byte("0123456789"), testServerOptions{})
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/file.bin",
+		OutFilename: outFilename,
+		RangeStart:  10,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+
+	if err := d.Download(); !errors.Is(err, ErrRangeNotSatisfiable) {
+		t.Errorf("Expected ErrRangeNotSatisfiable, got %v", err)
+	}
+}
+
+func TestSubRangeNotSatisfiableMessage(t *testing.T) {
+	// the HEAD promises 100 bytes but the parts are refused
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", "100")
+			return
+		}
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+	}))
+	defer server.Close()
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/file.bin",
+		OutFilename: outFilename,
+		RangeStart:  20,
+		RangeEnd:    29,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+
+	err = d.Download()
+	if !errors.Is(err, ErrRangeNotSatisfiable) {
+		t.Fatalf("Expected ErrRangeNotSatisfiable, got %v", err)
+	}
+	// the error names the range which was requested
+	if !strings.Contains(err.Error(), "bytes=20-29") {
+		t.Errorf("Expected the error to name bytes=20-29, got %v", err)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	testCases := []struct {
+		Header   string
+		Expected contentRange
+		Valid    bool
+	}{
+		{"bytes 0-0/12345", contentRange{0, 0, 12345}, true},
+		{"bytes 100-199/12345", contentRange{100, 199, 12345}, true},
+		{"bytes 0-499/*", contentRange{0, 499, -1}, true},
+		{"bytes */12345", contentRange{-1, -1, 12345}, true},
+		{" bytes  0-0/1 ", contentRange{0, 0, 1}, true},
+		{"", contentRange{}, false},
+		{"bytes */*", contentRange{}, false},
+		{"items 0-0/10", contentRange{}, false},
+		{"bytes 0-0", contentRange{}, false},
+		{"bytes 5-4/10", contentRange{}, false},
+		{"bytes 0-10/10", contentRange{}, false},
+		{"bytes a-b/10", contentRange{}, false},
+		{"bytes 0-1/-5", contentRange{}, false},
+	}
+
+	for _, testCase := range testCases {
+		cr, err := parseContentRange(testCase.Header)
+		if testCase.Valid && err != nil {
+			t.Errorf("%q: Expected to be valid, got %v", testCase.Header, err)
+		}
+		if !testCase.Valid && err == nil {
+			t.Errorf("%q: Expected an error, got %+v", testCase.Header, cr)
+		}
+		if cr != testCase.Expected {
+			t.Errorf("%q: Expected %+v, got %+v", testCase.Header, testCase.Expected, cr)
+		}
+	}
+}
+
+func TestWrongContentRange(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	// always sends the same bytes, whatever range is asked
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			r.Header.Set("Range", "bytes=100-199")
+		}
+		http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(original))
+	}))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		OutFilename: outFilename,
+		Concurrency: 4,
+		MinPartSize: 1,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	defer d.Cleanup()
+
+	if err := d.Download(); err == nil || !strings.Contains(err.Error(), "instead of") {
+		t.Errorf("Expected the wrong range to be refused, got %v", err)
+	}
+}
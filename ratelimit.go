@@ -0,0 +1,42 @@
+package downloader
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// Creates a limiter which allows bytesPerSecond bytes per second
+func newRateLimiter(bytesPerSecond int64) *rate.Limiter {
+	burst := int(bytesPerSecond)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), burst)
+}
+
+// rateLimitedReader blocks reads until the shared limiter allows them,
+// so all readers sharing the same limiter are throttled together
+type rateLimitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	// never ask the limiter for more than it can grant at once
+	if len(p) > r.limiter.Burst() {
+		p = p[:r.limiter.Burst()]
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,35 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedReader(t *testing.T) {
+	data := make([]byte, 3000)
+	limiter := newRateLimiter(1000)
+
+	reader := &rateLimitedReader{
+		ctx:     context.Background(),
+		reader:  bytes.NewReader(data),
+		limiter: limiter,
+	}
+
+	start := time.Now()
+	read, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+
+	if len(read) != len(data) {
+		t.Errorf("Expected to read %d bytes, got %d", len(data), len(read))
+	}
+	// the first 1000 bytes are allowed immediately by the burst
+	if elapsed < 1500*time.Millisecond {
+		t.Errorf("Expected reading to be throttled to ~2s, took %v", elapsed)
+	}
+}
@@ -0,0 +1,128 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// Returned by Reader when the requested bytes are not downloaded
+// and won't be, since the download has stopped
+var ErrNotDownloaded = errors.New("Requested bytes are not downloaded")
+
+// how often a blocked read checks whether its bytes are downloaded
+const readerPollInterval = 50 * time.Millisecond
+
+// Reader reads the file while it's being downloaded, e.g. to play a video
+// before the download is finished. Reads of bytes which are not downloaded
+// yet block until they are. Wrap it with io.NewSectionReader(r, 0, Size())
+// to get an io.ReadSeeker.
+type Reader struct {
+	d   *Downloader
+	ctx context.Context
+}
+
+// Returns a Reader over the file, which must be downloaded with
+// SinglePreallocatedFile or WorkStealing so it's written in place.
+// Cancelling ctx stops the blocked reads.
+func (d *Downloader) NewReader(ctx context.Context) (*Reader, error) {
+	if !d.config.SinglePreallocatedFile && !d.config.WorkStealing {
+		return nil, errors.New("Reading while downloading requires SinglePreallocatedFile or WorkStealing")
+	}
+
+	return &Reader{d: d, ctx: ctx}, nil
+}
+
+// Returns the size of the file, -1 until the download has started
+func (r *Reader) Size() int64 {
+	r.d.partsMu.Lock()
+	defer r.d.partsMu.Unlock()
+
+	if len(r.d.partStats) == 0 {
+		return -1
+	}
+	return r.d.partStats[len(r.d.partStats)-1].Stop + 1
+}
+
+// Reports whether the n bytes at off are downloaded, so ReadAt won't block
+func (r *Reader) Available(off, n int64) bool {
+	r.d.partsMu.Lock()
+	defer r.d.partsMu.Unlock()
+
+	return r.availableLocked(off) >= n
+}
+
+// ReadAt reads len(p) bytes at off, waiting for them to be downloaded.
+// It returns ErrNotDownloaded if the download stops without them.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	read := 0
+	for read < len(p) {
+		n, err := r.readAvailable(p[read:], off+int64(read))
+		read += n
+		if err != nil {
+			return read, err
+		}
+		if n > 0 {
+			continue
+		}
+
+		switch r.d.State() {
+		case StateCompleted:
+			n, err := r.readCompleted(p[read:], off+int64(read))
+			return read + n, err
+		case StatePaused, StateFailed:
+			return read, ErrNotDownloaded
+		}
+		select {
+		case <-r.ctx.Done():
+			return read, r.ctx.Err()
+		case <-time.After(readerPollInterval):
+		}
+	}
+
+	return read, nil
+}
+
+// Reads what is downloaded at off from the file being written,
+// returns io.EOF at the end of the file
+func (r *Reader) readAvailable(p []byte, off int64) (int, error) {
+	r.d.partsMu.Lock()
+	defer r.d.partsMu.Unlock()
+
+	if len(r.d.partStats) > 0 && off >= r.d.partStats[len(r.d.partStats)-1].Stop+1 {
+		return 0, io.EOF
+	}
+	available := r.availableLocked(off)
+	if available <= 0 || r.d.prealloc == nil {
+		return 0, nil
+	}
+	if available < int64(len(p)) {
+		p = p[:available]
+	}
+	return r.d.prealloc.ReadAt(p, off)
+}
+
+// Returns the number of bytes downloaded in a row from off,
+// the caller must hold partsMu
+func (r *Reader) availableLocked(off int64) int64 {
+	var available int64
+	for _, stat := range r.d.partStats {
+		if off+available < stat.Start || off+available > stat.Stop {
+			continue
+		}
+		// a part is downloaded from its start on
+		end := stat.Start + stat.Downloaded
+		if off+available >= end {
+			break
+		}
+		available = end - off
+	}
+
+	return available
+}
+
+// Reads from the output file once the download is completed
+func (r *Reader) readCompleted(p []byte, off int64) (int, error) {
+	return r.d.readFileAt(r.d.config.OutFilename, p, off)
+}
@@ -0,0 +1,123 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReaderWhileDownloading(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	server := newTestServer(t, original, testServerOptions{
+		Latency:   10 * time.Millisecond,
+		ChunkSize: 16 * 1024,
+	})
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:                    server.URL + "/book.pdf",
+		Concurrency:            4,
+		MinPartSize:            1,
+		OutFilename:            outFilename,
+		SinglePreallocatedFile: true,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	r, err := d.NewReader(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Download()
+	}()
+
+	// the beginning is readable long before the download is finished
+	head := make([]byte, 64*1024)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original[:len(head)], head) {
+		t.Error("Expected the first bytes of the file")
+	}
+	if d.State() != StateDownloading {
+		t.Errorf("Expected the download to be in progress, got %s", d.State())
+	}
+
+	// reading all of it waits for the rest
+	all, err := ioutil.ReadAll(io.NewSectionReader(r, 0, int64(len(original))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, all) {
+		t.Error("Expected the whole file")
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReaderNotDownloaded(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	server := newTestServer(t, original, testServerOptions{
+		Latency:   10 * time.Millisecond,
+		ChunkSize: 16 * 1024,
+	})
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	var d *Downloader
+	d, err = NewFromConfig(&Config{
+		Url:                    server.URL + "/book.pdf",
+		Concurrency:            2,
+		MinPartSize:            1,
+		OutFilename:            outFilename,
+		SinglePreallocatedFile: true,
+		OnProgress: func(downloaded, total int64) {
+			d.Pause()
+		},
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	defer d.Cleanup()
+	r, err := d.NewReader(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	last := make([]byte, 10)
+	if _, err := r.ReadAt(last, int64(len(original)-len(last))); !errors.Is(err, ErrNotDownloaded) {
+		t.Errorf("Expected ErrNotDownloaded for the end of a paused download, got %v", err)
+	}
+	if r.Available(0, int64(len(original))) {
+		t.Error("Expected the file not to be available")
+	}
+
+	plain, err := NewFromConfig(&Config{Url: server.URL + "/book.pdf", OutFilename: outFilename})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if _, err := plain.NewReader(context.Background()); err == nil {
+		t.Error("Expected an error without SinglePreallocatedFile")
+	}
+}
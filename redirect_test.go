@@ -0,0 +1,213 @@
+package downloader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/files/", http.StripPrefix("/files/", http.FileServer(http.Dir("./files/"))))
+	mux.Handle("/latest", http.RedirectHandler("/files/book.pdf", http.StatusFound))
+	mux.Handle("/download", http.RedirectHandler("/latest", http.StatusFound))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	outputDir, err := ioutil.TempDir("", "go_dl_redirect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/download",
+		Concurrency: 4,
+		OutputDir:   outputDir,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.ResolvedURL() != server.URL+"/files/book.pdf" {
+		t.Errorf("Expected resolved url to be %s, got %s", server.URL+"/files/book.pdf", d.ResolvedURL())
+	}
+
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	// the filename comes from the final url
+	downloaded, err := ioutil.ReadFile(filepath.Join(outputDir, "book.pdf"))
+	if err != nil {
+		t.Fatalf("Cannot read the downloaded file: %v", err)
+	}
+	if !bytes.Equal(original, downloaded) {
+		t.Error("Downloaded file is not the same as original file")
+	}
+
+	// too many redirects
+	d, err = NewFromConfig(&Config{
+		Url:          server.URL + "/download",
+		OutputDir:    outputDir,
+		MaxRedirects: 1,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err == nil {
+		t.Error("Expected an error after too many redirects")
+	}
+}
+
+func TestProxyURL(t *testing.T) {
+	// the proxy receives the requests for the unreachable host and serves the file itself
+	var mu sync.Mutex
+	proxied := 0
+	files := http.FileServer(http.Dir("./files/"))
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied++
+		mu.Unlock()
+		files.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         "http://go-dl.invalid/book.pdf",
+		Concurrency: 3,
+		MinPartSize: 1,
+		OutFilename: outFilename,
+		ProxyURL:    proxy.URL,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the HEAD request and the three parts
+	mu.Lock()
+	defer mu.Unlock()
+	if proxied != 4 {
+		t.Errorf("Expected 4 requests through the proxy, got %d", proxied)
+	}
+
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	downloaded, err := ioutil.ReadFile(outFilename)
+	if err != nil {
+		t.Fatalf("Cannot read the downloaded file: %v", err)
+	}
+	if !bytes.Equal(original, downloaded) {
+		t.Error("Downloaded file is not the same as the original")
+	}
+}
+
+func TestInvalidProxyURL(t *testing.T) {
+	_, err := NewFromConfig(&Config{
+		Url:      "http://localhost/book.pdf",
+		ProxyURL: "://bad",
+	})
+	if err == nil {
+		t.Error("Expected an error for an invalid ProxyURL")
+	}
+}
+
+func TestForwardAuthOnRedirect(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	var mu sync.Mutex
+	var leaked []string
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		for _, header := range []string{"Authorization", "X-Api-Key"} {
+			if r.Header.Get(header) != "" {
+				leaked = append(leaked, header)
+			}
+		}
+		mu.Unlock()
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(data))
+	}))
+	defer cdn.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); !ok || r.Header.Get("X-Api-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, cdn.URL+"/file.bin?signature=abc", http.StatusFound)
+	}))
+	defer origin.Close()
+
+	for _, forward := range []bool{false, true} {
+		mu.Lock()
+		leaked = nil
+		mu.Unlock()
+
+		outFilename := tempOutFilename(t)
+		d, err := NewFromConfig(&Config{
+			Url:                   origin.URL + "/download",
+			OutFilename:           outFilename,
+			Concurrency:           4,
+			MinPartSize:           1,
+			BasicAuthUser:         "user",
+			BasicAuthPassword:     "pass",
+			Headers:               http.Header{"X-Api-Key": {"secret"}, "X-Trace": {"1"}},
+			ForwardAuthOnRedirect: forward,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+		if err := d.Download(); err != nil {
+			t.Fatal(err)
+		}
+		downloaded, err := ioutil.ReadFile(outFilename)
+		os.Remove(outFilename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, downloaded) {
+			t.Error("Downloaded file is not the same as original file")
+		}
+
+		mu.Lock()
+		if !forward && len(leaked) != 0 {
+			t.Errorf("Expected no credentials sent to the redirect host, got %v", leaked)
+		}
+		if forward && len(leaked) == 0 {
+			t.Error("Expected the credentials forwarded to the redirect host")
+		}
+		mu.Unlock()
+	}
+}
+
+func TestIsAuthHeader(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"Authorization": true,
+		"Cookie":        true,
+		"X-Api-Key":     true,
+		"Private-Token": true,
+		"X-Auth-User":   true,
+		"Accept":        false,
+		"User-Agent":    false,
+		"X-Trace":       false,
+	} {
+		if isAuthHeader(name) != expected {
+			t.Errorf("Expected isAuthHeader(%s) to be %v, got %v", name, expected, !expected)
+		}
+	}
+}
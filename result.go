@@ -0,0 +1,72 @@
+package downloader
+
+import (
+	"context"
+	"time"
+)
+
+// Status is how a download has ended
+type Status int
+
+const (
+	StatusCompleted Status = iota
+	// stopped by Pause, can be continued with Resume
+	StatusPaused
+	// the output file already exists, see OnExistSkip and OnExistUpdate
+	StatusSkipped
+	StatusFailed
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusCompleted:
+		return "completed"
+	case StatusPaused:
+		return "paused"
+	case StatusSkipped:
+		return "skipped"
+	case StatusFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// Result is the outcome of a download
+type Result struct {
+	Status Status
+	// absolute path of the output file
+	OutputPath string
+	// bytes of the file downloaded so far, including
+	// the ones downloaded before a resume
+	BytesDownloaded int64
+	// how long the download call took
+	Duration time.Duration
+	// why the download failed, nil unless Status is StatusFailed
+	Err error
+}
+
+// DownloadResult is like DownloadContext, but tells how the download
+// has ended instead of only returning its error
+func (d *Downloader) DownloadResult(ctx context.Context) Result {
+	start := time.Now()
+	err := d.DownloadContext(ctx)
+
+	result := Result{
+		OutputPath:      d.OutputPath(),
+		BytesDownloaded: d.Stats().Downloaded,
+		Duration:        time.Since(start),
+		Err:             err,
+	}
+	switch {
+	case err != nil:
+		result.Status = StatusFailed
+	case d.isPaused():
+		result.Status = StatusPaused
+	case d.skipped:
+		result.Status = StatusSkipped
+	default:
+		result.Status = StatusCompleted
+	}
+
+	return result
+}
@@ -0,0 +1,67 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// how long to wait before opening a part file again when the
+// process ran out of file descriptors
+const reopenDelay = 100 * time.Millisecond
+
+// IsRetryable reports whether a download which failed with err may
+// succeed if it's tried again later. Network errors, timeouts and
+// 5xx, 408 and 429 responses are retryable, while other 4xx responses,
+// invalid urls and a changed remote file are permanent.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, ErrRemoteFileChanged) || errors.Is(err, ErrRangeNotSatisfiable) || errors.Is(err, errRangesIgnored) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	if errors.Is(err, ErrStalled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	// the client wraps transport failures in url.Error,
+	// an invalid url fails before anything is sent
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Op != "parse"
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Calls open, and once more after a short delay if it failed because
+// there are too many open files, as the other parts close theirs
+func (d *Downloader) retryOpen(open func() (io.WriteCloser, error)) (io.WriteCloser, error) {
+	f, err := open()
+	if err == nil || !isTooManyOpenFiles(err) {
+		return f, err
+	}
+
+	d.debugf("Cannot open part file: %v, retrying in %v", err, reopenDelay)
+	select {
+	case <-d.context.Done():
+		return nil, err
+	case <-time.After(reopenDelay):
+	}
+	return open()
+}
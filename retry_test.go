@@ -0,0 +1,210 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	testCases := []struct {
+		Err       error
+		Retryable bool
+	}{
+		{Err: nil, Retryable: false},
+		{Err: &StatusError{StatusCode: 503, Status: "503 Service Unavailable"}, Retryable: true},
+		{Err: &StatusError{StatusCode: 429, Status: "429 Too Many Requests"}, Retryable: true},
+		{Err: &StatusError{StatusCode: 408, Status: "408 Request Timeout"}, Retryable: true},
+		{Err: &StatusError{StatusCode: 404, Status: "404 Not Found"}, Retryable: false},
+		{Err: fmt.Errorf("part 1: %w", &StatusError{StatusCode: 403, Status: "403 Forbidden"}), Retryable: false},
+		{Err: fmt.Errorf("part 1: %w", ErrRemoteFileChanged), Retryable: false},
+		{Err: ErrStalled, Retryable: true},
+		{Err: &url.Error{Op: "Get", URL: "http://localhost", Err: io.EOF}, Retryable: true},
+		{Err: &url.Error{Op: "parse", URL: "::", Err: errors.New("missing protocol scheme")}, Retryable: false},
+		{Err: context.Canceled, Retryable: false},
+	}
+
+	for _, testCase := range testCases {
+		if retryable := IsRetryable(testCase.Err); retryable != testCase.Retryable {
+			t.Errorf("Expected IsRetryable(%v) to be %v", testCase.Err, testCase.Retryable)
+		}
+	}
+}
+
+func TestNotRetryableFailsFast(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", "1000")
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			requests++
+			mu.Unlock()
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:          server.URL + "/book.pdf",
+		OutFilename:  outFilename,
+		MaxRetries:   3,
+		RetryBackoff: time.Second,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	defer d.Cleanup()
+
+	err = d.Download()
+	if err == nil || IsRetryable(err) {
+		t.Errorf("Expected a permanent error, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 1 {
+		t.Errorf("Expected 1 request, got %d", requests)
+	}
+}
+
+func TestRetryOpen(t *testing.T) {
+	d := &Downloader{config: &Config{}, context: context.Background()}
+	tooMany := &os.PathError{Op: "open", Path: "book.pdf.part1", Err: syscall.EMFILE}
+
+	opens := 0
+	f, err := d.retryOpen(func() (io.WriteCloser, error) {
+		opens++
+		if opens == 1 {
+			return nil, tooMany
+		}
+		return nopWriteCloser{}, nil
+	})
+	if err != nil || f == nil {
+		t.Errorf("Expected the second open to succeed, got %v", err)
+	}
+	if opens != 2 {
+		t.Errorf("Expected 2 opens, got %d", opens)
+	}
+
+	opens = 0
+	_, err = d.retryOpen(func() (io.WriteCloser, error) {
+		opens++
+		return nil, &os.PathError{Op: "open", Path: "book.pdf.part1", Err: syscall.EACCES}
+	})
+	if err == nil || opens != 1 {
+		t.Errorf("Expected a permanent error after 1 open, got %v after %d", err, opens)
+	}
+}
+
+type nopWriteCloser struct{}
+
+func (nopWriteCloser) Write(p []byte) (int, error) { return len(p), nil }
+func (nopWriteCloser) Close() error                { return nil }
+
+func TestPartOpenError(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		OutFilename: outFilename,
+		Concurrency: 2,
+		MinPartSize: 1,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	defer d.Cleanup()
+
+	// a directory in place of the part file can't be opened for writing
+	if err := os.Mkdir(d.getPartFilename(2), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d.getPartFilename(2))
+
+	err = d.Download()
+	if err == nil || !strings.Contains(err.Error(), "part 2") {
+		t.Errorf("Expected the part 2 open error, got %v", err)
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+	parts := splitRanges(len(original), 4)
+
+	// the second part is forbidden, the others are slow
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			if strings.HasPrefix(r.Header.Get("Range"), fmt.Sprintf("bytes=%d-", parts[1].Start)) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(500 * time.Millisecond):
+			}
+		}
+		http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(original))
+	}))
+	defer server.Close()
+
+	for _, continueOnError := range []bool{false, true} {
+		outFilename := tempOutFilename(t)
+		d, err := NewFromConfig(&Config{
+			Url:                 server.URL + "/book.pdf",
+			OutFilename:         outFilename,
+			Concurrency:         4,
+			MinPartSize:         1,
+			ContinueOnPartError: continueOnError,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+
+		start := time.Now()
+		err = d.Download()
+		elapsed := time.Since(start)
+		if err == nil || !strings.Contains(err.Error(), "part 2") {
+			t.Errorf("Expected part 2 to fail, got %v", err)
+		}
+
+		completed := 0
+		for _, stat := range d.PartStats() {
+			if stat.Complete {
+				completed++
+			}
+		}
+		if continueOnError && completed != 3 {
+			t.Errorf("Expected the other 3 parts to complete, got %d", completed)
+		}
+		if !continueOnError && (completed != 0 || elapsed >= 500*time.Millisecond) {
+			t.Errorf("Expected the other parts to stop right away, %d completed after %v", completed, elapsed)
+		}
+
+		d.Cleanup()
+		os.Remove(outFilename)
+	}
+}
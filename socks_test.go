@@ -0,0 +1,250 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A minimal SOCKS5 server, requires the credentials if user isn't empty
+type socksServer struct {
+	listener net.Listener
+	user     string
+	password string
+
+	mu      sync.Mutex
+	tunnels int
+}
+
+func newSocksServer(t *testing.T, user, password string) *socksServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &socksServer{listener: listener, user: user, password: password}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *socksServer) URL() string {
+	return "socks5://" + s.listener.Addr().String()
+}
+
+func (s *socksServer) Close() {
+	s.listener.Close()
+}
+
+func (s *socksServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	// greeting: version, number of methods and the methods
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return
+	}
+	if s.user == "" {
+		conn.Write([]byte{5, 0})
+	} else {
+		conn.Write([]byte{5, 2})
+		if !s.authenticate(conn) {
+			return
+		}
+	}
+
+	// request: version, command, reserved, address type, address and port
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return
+	}
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		length := make([]byte, 1)
+		io.ReadFull(conn, length)
+		name := make([]byte, length[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return
+	}
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	s.mu.Lock()
+	s.tunnels++
+	s.mu.Unlock()
+
+	// close both ends once either side is done
+	go func() {
+		io.Copy(target, conn)
+		target.Close()
+		conn.Close()
+	}()
+	io.Copy(conn, target)
+}
+
+// username/password subnegotiation of RFC 1929
+func (s *socksServer) authenticate(conn net.Conn) bool {
+	readField := func() string {
+		length := make([]byte, 1)
+		io.ReadFull(conn, length)
+		field := make([]byte, length[0])
+		io.ReadFull(conn, field)
+		return string(field)
+	}
+
+	if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
+		return false
+	}
+	user, password := readField(), readField()
+	if user != s.user || password != s.password {
+		conn.Write([]byte{1, 1})
+		return false
+	}
+	conn.Write([]byte{1, 0})
+	return true
+}
+
+func TestSocks5Proxy(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	testCases := []struct {
+		User        string
+		Password    string
+		Credentials string
+		Succeeds    bool
+	}{
+		{Succeeds: true},
+		{User: "user", Password: "secret", Credentials: "user:secret@", Succeeds: true},
+		{User: "user", Password: "secret", Credentials: "user:wrong@", Succeeds: false},
+	}
+
+	for _, testCase := range testCases {
+		proxy := newSocksServer(t, testCase.User, testCase.Password)
+		defer proxy.Close()
+
+		outFilename := tempOutFilename(t)
+		defer os.Remove(outFilename)
+
+		d, err := NewFromConfig(&Config{
+			Url:         server.URL + "/book.pdf",
+			Concurrency: 3,
+			MinPartSize: 1,
+			OutFilename: outFilename,
+			ProxyURL:    "socks5://" + testCase.Credentials + proxy.listener.Addr().String(),
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+		err = d.Download()
+		if testCase.Succeeds != (err == nil) {
+			t.Errorf("Credentials %q: expected success to be %v, got %v", testCase.Credentials, testCase.Succeeds, err)
+			continue
+		}
+		if !testCase.Succeeds {
+			continue
+		}
+
+		downloaded, err := ioutil.ReadFile(outFilename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(original, downloaded) {
+			t.Error("Downloaded file is not the same as the original")
+		}
+		proxy.mu.Lock()
+		if proxy.tunnels == 0 {
+			t.Error("Expected the requests to go through the proxy")
+		}
+		proxy.mu.Unlock()
+	}
+}
+
+func TestSocks5ProxyCancel(t *testing.T) {
+	// never finishes sending the body
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000000")
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Write(make([]byte, 1000))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	proxy := newSocksServer(t, "", "")
+	defer proxy.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		OutFilename: outFilename,
+		ProxyURL:    proxy.URL(),
+		OnProgress: func(downloaded, total int64) {
+			cancel()
+		},
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- d.DownloadContext(ctx)
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the download to be cancelled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the cancellation to interrupt the transfer")
+	}
+}
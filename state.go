@@ -0,0 +1,196 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// State of a Downloader
+type State int
+
+const (
+	// not started yet
+	StateIdle State = iota
+	StateDownloading
+	// stopped by Pause, can be continued with Resume
+	StatePaused
+	StateCompleted
+	StateFailed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateDownloading:
+		return "downloading"
+	case StatePaused:
+		return "paused"
+	case StateCompleted:
+		return "completed"
+	case StateFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// Returns the current state of the download
+func (d *Downloader) State() State {
+	d.stateMu.Lock()
+	defer d.stateMu.Unlock()
+
+	return d.state
+}
+
+// Changes the state and notifies OnStateChange without holding the lock,
+// so the callback can safely call back into the Downloader
+func (d *Downloader) setState(state State) {
+	d.stateMu.Lock()
+	changed := d.state != state
+	d.state = state
+	d.stateMu.Unlock()
+
+	if changed && d.config.OnStateChange != nil {
+		d.config.OnStateChange(state)
+	}
+}
+
+// Pause stops all the parts gracefully, the download returns once
+// every part file is flushed and the state becomes StatePaused.
+// With FlushOnPause, Pause itself waits for that. It does nothing
+// if no download is running.
+func (d *Downloader) Pause() {
+	d.stateMu.Lock()
+	cancel, done := d.cancel, d.runDone
+	if cancel == nil {
+		d.stateMu.Unlock()
+		return
+	}
+	d.paused = true
+	d.stateMu.Unlock()
+
+	cancel()
+	if d.config.FlushOnPause {
+		<-done
+	}
+}
+
+func (d *Downloader) isPaused() bool {
+	d.stateMu.Lock()
+	defer d.stateMu.Unlock()
+
+	return d.paused
+}
+
+// Resume continues a paused download from where its parts have stopped.
+// The file's size is already known, so it's not requested again.
+func (d *Downloader) Resume(ctx context.Context) error {
+	d.stateMu.Lock()
+	d.config.Resume = true
+	d.paused = false
+	d.stateMu.Unlock()
+
+	if d.contentSize > 0 {
+		return d.run(ctx, func() error {
+			return d.downloadParts(ctx, d.contentSize)
+		})
+	}
+
+	return d.DownloadContext(ctx)
+}
+
+// Reset clears what is left of the previous download, so Download can
+// be called again for the same Config, e.g. to fetch an updated file.
+// The output file is chosen again when it starts, so with OnExistRename
+// the file of the previous download is kept. The client and the rate
+// limiter are kept. A paused download can't be
+// resumed afterwards, unless Config.Resume is set.
+func (d *Downloader) Reset() error {
+	d.stateMu.Lock()
+	if d.state == StateDownloading {
+		d.stateMu.Unlock()
+		return errors.New("Cannot reset while downloading")
+	}
+	d.paused = false
+	d.context, d.cancel = nil, nil
+	d.config.Resume = d.resume
+	d.config.OutFilename = d.outFilename
+	d.renamePending = true
+	d.progress = nil
+	d.stateMu.Unlock()
+
+	d.etag, d.lastModified, d.ifRange = "", "", ""
+	d.contentSize, d.remoteSize, d.contentType = 0, 0, ""
+	d.acceptRanges = false
+	d.upToDate, d.skipped = false, false
+	d.resolvedURL = ""
+	d.urlChecksum = nil
+	d.partsMu.Lock()
+	d.partStats = nil
+	d.partsMu.Unlock()
+
+	d.setState(StateIdle)
+	return nil
+}
+
+// Derives the context Pause cancels from ctx, the returned
+// function must be called once the download step is over
+func (d *Downloader) setContext(ctx context.Context) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+
+	d.stateMu.Lock()
+	d.context = ctx
+	d.cancel = cancel
+	d.stateMu.Unlock()
+
+	return cancel
+}
+
+// Returns nil if err is caused by a pause, e.g. of the HEAD request,
+// which leaves the download paused rather than failed
+func (d *Downloader) pauseError(err error) error {
+	if d.context.Err() != nil && d.isPaused() {
+		return nil
+	}
+	return err
+}
+
+// Runs a download step with a fresh context and keeps the state up to date
+func (d *Downloader) run(ctx context.Context, download func() error) error {
+	done := make(chan struct{})
+	d.stateMu.Lock()
+	d.runDone = done
+	d.paused = false
+	d.stateMu.Unlock()
+	defer close(done)
+
+	cancel := d.setContext(ctx)
+	defer cancel()
+	defer func() {
+		// a later Pause has nothing to stop
+		d.stateMu.Lock()
+		d.cancel = nil
+		d.stateMu.Unlock()
+	}()
+
+	start := time.Now()
+	atomic.StoreInt64(&d.runBytes, 0)
+	d.setState(StateDownloading)
+	err := download()
+	if p := d.currentProgress(); p != nil {
+		p.flush()
+	}
+	d.observeRun(start, err)
+	switch {
+	case err != nil:
+		d.setState(StateFailed)
+	case d.isPaused():
+		d.setState(StatePaused)
+	default:
+		d.setState(StateCompleted)
+	}
+
+	return err
+}
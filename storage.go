@@ -0,0 +1,179 @@
+package downloader
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// WriteSeekCloser is a file of a Storage opened for writing
+type WriteSeekCloser interface {
+	io.Writer
+	io.Seeker
+	io.Closer
+}
+
+// ReadSeekCloser is a file of a Storage opened for reading
+type ReadSeekCloser interface {
+	io.Reader
+	io.Seeker
+	io.Closer
+}
+
+// Storage is where the downloaded file, its part files and the resume
+// metadata are saved, e.g. in memory or in an object store. The names are
+// the paths derived from OutFilename and TempDir. A missing file must be
+// reported with an error satisfying os.IsNotExist.
+type Storage interface {
+	// Create opens the named file for writing, creating it if it doesn't
+	// exist. Unlike os.Create its content is kept, so a resumed part can
+	// be appended to, see Truncate.
+	Create(name string) (WriteSeekCloser, error)
+	// Open opens the named file for reading
+	Open(name string) (ReadSeekCloser, error)
+	Rename(oldname, newname string) error
+	Remove(name string) error
+	Stat(name string) (os.FileInfo, error)
+	// Truncate changes the size of the named file
+	Truncate(name string, size int64) error
+}
+
+// LocalStorage saves the files on the local file system, it's
+// used when Config.Storage is nil. Its files are *os.File.
+type LocalStorage struct{}
+
+func (LocalStorage) Create(name string) (WriteSeekCloser, error) {
+	return os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0666)
+}
+
+func (LocalStorage) Open(name string) (ReadSeekCloser, error) {
+	return os.Open(name)
+}
+
+func (LocalStorage) Rename(oldname, newname string) error {
+	return os.Rename(oldname, newname)
+}
+
+func (LocalStorage) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (LocalStorage) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (LocalStorage) Truncate(name string, size int64) error {
+	return os.Truncate(name, size)
+}
+
+// Reports whether the files are on the local file system, where the
+// directories, the free space and the special files can be checked
+func (d *Downloader) isLocalStorage() bool {
+	_, ok := d.storage.(LocalStorage)
+	return ok
+}
+
+// Creates the named file, or empties it if it exists
+func (d *Downloader) createTruncated(name string) (WriteSeekCloser, error) {
+	f, err := d.storage.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.storage.Truncate(name, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reads the whole named file
+func (d *Downloader) readFile(name string) ([]byte, error) {
+	f, err := d.storage.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ioutil.ReadAll(f)
+}
+
+// Replaces the content of the named file with data, syncing it to
+// disk if sync is set
+func (d *Downloader) writeFile(name string, data []byte, sync bool) error {
+	f, err := d.createTruncated(name)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if sync {
+		if err := syncFile(f); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// Reads len(p) bytes of the named file starting at off, like io.ReaderAt
+func (d *Downloader) readFileAt(name string, p []byte, off int64) (int, error) {
+	f, err := d.storage.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if r, ok := f.(io.ReaderAt); ok {
+		return r.ReadAt(p, off)
+	}
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(f, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Flushes f to durable storage if its storage supports it
+func syncFile(f interface{}) error {
+	if s, ok := f.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Opens the named file, creating it if it doesn't exist, positioned at
+// its end to append to it, and returns its size
+func (d *Downloader) openForAppend(name string) (WriteSeekCloser, int64, error) {
+	f, err := d.storage.Create(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, size, nil
+}
+
+// Feeds the first size bytes of the named file to the checksums,
+// e.g. the bytes downloaded before a resume
+func (d *Downloader) hashFile(name string, size int64, sums []*checksum) error {
+	if size == 0 || len(sums) == 0 {
+		return nil
+	}
+
+	f, err := d.storage.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.CopyN(checksumWriter(ioutil.Discard, sums), f, size)
+	return err
+}
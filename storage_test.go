@@ -0,0 +1,244 @@
+package downloader
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStorage keeps the files in memory
+type memStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{files: map[string][]byte{}}
+}
+
+func (s *memStorage) Create(name string) (WriteSeekCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[name]; !ok {
+		s.files[name] = []byte{}
+	}
+	return &memFile{storage: s, name: name}, nil
+}
+
+func (s *memStorage) Open(name string) (ReadSeekCloser, error) {
+	if _, err := s.Stat(name); err != nil {
+		return nil, err
+	}
+	return &memFile{storage: s, name: name}, nil
+}
+
+func (s *memStorage) Rename(oldname, newname string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[oldname]
+	if !ok {
+		return &os.PathError{Op: "rename", Path: oldname, Err: os.ErrNotExist}
+	}
+	delete(s.files, oldname)
+	s.files[newname] = data
+	return nil
+}
+
+func (s *memStorage) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[name]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(s.files, name)
+	return nil
+}
+
+func (s *memStorage) Stat(name string) (os.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return memFileInfo{name: path.Base(name), size: int64(len(data))}, nil
+}
+
+func (s *memStorage) Truncate(name string, size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[name]
+	if !ok {
+		return &os.PathError{Op: "truncate", Path: name, Err: os.ErrNotExist}
+	}
+	if int64(len(data)) >= size {
+		s.files[name] = data[:size]
+	} else {
+		s.files[name] = append(data, make([]byte, size-int64(len(data)))...)
+	}
+	return nil
+}
+
+// the names of the files in the storage
+func (s *memStorage) names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name := range s.files {
+		names = append(names, name)
+	}
+	return names
+}
+
+type memFile struct {
+	storage *memStorage
+	name    string
+	offset  int64
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	f.storage.mu.Lock()
+	defer f.storage.mu.Unlock()
+	data := f.storage.files[f.name]
+	if end := off + int64(len(p)); end > int64(len(data)) {
+		data = append(data, make([]byte, end-int64(len(data)))...)
+	}
+	copy(data[off:], p)
+	f.storage.files[f.name] = data
+	return len(p), nil
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.storage.mu.Lock()
+	defer f.storage.mu.Unlock()
+	data := f.storage.files[f.name]
+	if off >= int64(len(data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		f.storage.mu.Lock()
+		offset += int64(len(f.storage.files[f.name]))
+		f.storage.mu.Unlock()
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+type memFileInfo struct {
+	name string
+	size int64
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() os.FileMode  { return 0666 }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return false }
+func (i memFileInfo) Sys() interface{}   { return nil }
+
+func TestMemoryStorage(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	for _, prealloc := range []bool{false, true} {
+		storage := newMemStorage()
+		outFilename := "go_dl_memory/book.pdf"
+		d, err := NewFromConfig(&Config{
+			Url:                    server.URL + "/book.pdf",
+			OutFilename:            outFilename,
+			Concurrency:            4,
+			MinPartSize:            1,
+			SinglePreallocatedFile: prealloc,
+			Storage:                storage,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+		if err := d.Download(); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(original, storage.files[outFilename]) {
+			t.Errorf("SinglePreallocatedFile %v: Downloaded file is not the same as original file", prealloc)
+		}
+		if names := storage.names(); len(names) != 1 {
+			t.Errorf("SinglePreallocatedFile %v: Expected only the downloaded file to be left, got %s", prealloc, strings.Join(names, ", "))
+		}
+		if _, err := os.Stat("go_dl_memory"); !os.IsNotExist(err) {
+			t.Error("Expected nothing to be written to the local file system")
+		}
+	}
+}
+
+// seqStorage's files can only be written sequentially
+type seqStorage struct {
+	*memStorage
+}
+
+func (s seqStorage) Create(name string) (WriteSeekCloser, error) {
+	f, err := s.memStorage.Create(name)
+	return struct{ WriteSeekCloser }{f}, err
+}
+
+func TestPreallocatedStorageWithoutWriteAt(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	d, err := NewFromConfig(&Config{
+		Url:                    server.URL + "/book.pdf",
+		OutFilename:            "book.pdf",
+		Concurrency:            4,
+		MinPartSize:            1,
+		SinglePreallocatedFile: true,
+		Storage:                seqStorage{newMemStorage()},
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err == nil || !strings.Contains(err.Error(), "io.WriterAt") {
+		t.Errorf("Expected an error about io.WriterAt, got %v", err)
+	}
+}
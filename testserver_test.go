@@ -0,0 +1,141 @@
+package downloader
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// options of a testServer, the zero value serves the data with ranges as
+// fast as possible
+type testServerOptions struct {
+	// sleeps before sending every chunk of the body
+	Latency time.Duration
+	// the connections take turns to sleep, as if they shared a slow link
+	SharedLatency bool
+	// size of the chunks the body is sent in, 32KB if zero
+	ChunkSize int
+	// ignores the Range header and hides Accept-Ranges
+	NoRanges bool
+	// ignores the Range header but still advertises Accept-Ranges
+	IgnoreRanges bool
+	// the first Failures GET requests are cut off after FailAfter bytes
+	Failures  int
+	FailAfter int64
+}
+
+// testServer serves data from memory under any path and records the
+// requests it receives
+type testServer struct {
+	*httptest.Server
+	data    []byte
+	options testServerOptions
+
+	mu       sync.Mutex
+	requests []*http.Request
+	// when each of the requests was received
+	times    []time.Time
+	failures int
+	// held while sleeping if SharedLatency is set
+	link sync.Mutex
+}
+
+func newTestServer(t *testing.T, data []byte, options testServerOptions) *testServer {
+	if options.ChunkSize == 0 {
+		options.ChunkSize = 32 * 1024
+	}
+
+	s := &testServer{data: data, options: options}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Clone(r.Context()))
+	s.times = append(s.times, time.Now())
+	fail := r.Method == http.MethodGet && s.failures < s.options.Failures
+	if fail {
+		s.failures++
+	}
+	s.mu.Unlock()
+
+	if s.options.NoRanges || s.options.IgnoreRanges {
+		r.Header.Del("Range")
+	}
+	if s.options.NoRanges {
+		w = hiddenRangesWriter{w}
+	}
+	writer := &chunkedWriter{ResponseWriter: w, options: s.options, fail: fail}
+	if s.options.SharedLatency {
+		writer.link = &s.link
+	}
+	http.ServeContent(writer, r, "", time.Time{}, bytes.NewReader(s.data))
+}
+
+// Returns the requests received so far
+func (s *testServer) Requests() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*http.Request(nil), s.requests...)
+}
+
+// Returns when each of the requests returned by Requests was received
+func (s *testServer) RequestTimes() []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]time.Time(nil), s.times...)
+}
+
+// writes the body in chunks with the configured latency, and aborts
+// the connection after FailAfter bytes if the request must fail
+type chunkedWriter struct {
+	http.ResponseWriter
+	options testServerOptions
+	fail    bool
+	written int64
+	// the server's link if SharedLatency is set
+	link *sync.Mutex
+}
+
+func (w *chunkedWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > w.options.ChunkSize {
+			chunk = chunk[:w.options.ChunkSize]
+		}
+		if w.fail && w.written+int64(len(chunk)) > w.options.FailAfter {
+			chunk = chunk[:w.options.FailAfter-w.written]
+		}
+		if w.link != nil {
+			w.link.Lock()
+			time.Sleep(w.options.Latency)
+			w.link.Unlock()
+		} else if w.options.Latency > 0 {
+			time.Sleep(w.options.Latency)
+		}
+
+		m, err := w.ResponseWriter.Write(chunk)
+		n += m
+		w.written += int64(m)
+		if err != nil {
+			return n, err
+		}
+		if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		if w.fail && w.written >= w.options.FailAfter {
+			// the client sees the connection closed mid-transfer
+			panic(http.ErrAbortHandler)
+		}
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
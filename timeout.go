@@ -0,0 +1,61 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Returned when no bytes arrive within StallTimeout
+var ErrStalled = errors.New("Connection stalled")
+
+// Derives the context of a single request, which
+// is cancelled after RequestTimeout if it's set
+func (d *Downloader) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.config.RequestTimeout > 0 {
+		return context.WithTimeout(ctx, d.config.RequestTimeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+// stallReader cancels the request if a read doesn't return
+// any bytes within timeout, so a dead connection doesn't block forever
+type stallReader struct {
+	reader  io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+	stalled int32
+}
+
+// Watches reads from r, cancel is called when it stalls.
+// Returns r as is if timeout is zero.
+func newStallReader(r io.Reader, timeout time.Duration, cancel context.CancelFunc) io.Reader {
+	if timeout <= 0 {
+		return r
+	}
+
+	s := &stallReader{reader: r, timeout: timeout}
+	s.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&s.stalled, 1)
+		cancel()
+	})
+	return s
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.reader.Read(p)
+	if atomic.LoadInt32(&s.stalled) == 1 {
+		return n, ErrStalled
+	}
+	if n > 0 {
+		s.timer.Reset(s.timeout)
+	}
+	if err != nil {
+		s.timer.Stop()
+	}
+
+	return n, err
+}
@@ -0,0 +1,158 @@
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStallTimeout(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	// the first request of every part sends a few bytes and then hangs
+	var mu sync.Mutex
+	stalled := make(map[int]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader := r.Header.Get("Range")
+		if r.Method == http.MethodGet && rangeHeader != "" {
+			var start, stop int
+			fmt.Sscanf(strings.TrimPrefix(rangeHeader, "bytes="), "%d-%d", &start, &stop)
+			mu.Lock()
+			first := !stalled[stop]
+			stalled[stop] = true
+			mu.Unlock()
+			if first {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, stop, len(original)))
+				w.Header().Set("Content-Length", fmt.Sprint(stop-start+1))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(original[start : start+100])
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+				return
+			}
+		}
+		http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(original))
+	}))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:          server.URL + "/book.pdf",
+		Concurrency:  4,
+		MinPartSize:  1,
+		OutFilename:  outFilename,
+		MaxRetries:   2,
+		RetryBackoff: 10 * time.Millisecond,
+		StallTimeout: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatalf("Expected download to succeed after the stalled parts are retried, got %v", err)
+	}
+
+	downloaded, err := ioutil.ReadFile(outFilename)
+	if err != nil {
+		t.Fatalf("Cannot read %s", outFilename)
+	}
+	if !bytes.Equal(original, downloaded) {
+		t.Error("Downloaded file is not the same as original file")
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:            server.URL + "/book.pdf",
+		OutFilename:    outFilename,
+		RequestTimeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Download()
+	}()
+	select {
+	case err := <-done:
+		if err == nil || errors.Is(err, ErrStalled) {
+			t.Errorf("Expected a timeout error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the download to time out")
+	}
+}
+
+func TestHeadInterrupted(t *testing.T) {
+	// never answers the HEAD request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		Pause          bool
+		RequestTimeout time.Duration
+	}{
+		{Pause: true},
+		{RequestTimeout: 100 * time.Millisecond},
+	}
+
+	for _, testCase := range testCases {
+		outFilename := tempOutFilename(t)
+		defer os.Remove(outFilename)
+
+		d, err := NewFromConfig(&Config{
+			Url:            server.URL + "/book.pdf",
+			OutFilename:    outFilename,
+			RequestTimeout: testCase.RequestTimeout,
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- d.Download()
+		}()
+		if testCase.Pause {
+			time.Sleep(50 * time.Millisecond)
+			d.Pause()
+		}
+
+		select {
+		case err := <-done:
+			if testCase.Pause && (err != nil || d.State() != StatePaused) {
+				t.Errorf("Expected the download to be paused, got %v in state %s", err, d.State())
+			}
+			if !testCase.Pause && (err == nil || d.State() != StateFailed) {
+				t.Errorf("Expected the HEAD request to time out, got %v in state %s", err, d.State())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the HEAD request to be interrupted")
+		}
+	}
+}
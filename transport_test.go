@@ -0,0 +1,131 @@
+package downloader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Starts a TLS server of book.pdf counting the connections it accepts
+// and the requests made over HTTP/2
+func newCountingServer(t testing.TB, http2 bool) (server *httptest.Server, conns, http2Requests func() int) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	var mu sync.Mutex
+	newConns, h2 := 0, 0
+	server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 {
+			mu.Lock()
+			h2++
+			mu.Unlock()
+		}
+		http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(original))
+	}))
+	server.EnableHTTP2 = http2
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			newConns++
+			mu.Unlock()
+		}
+	}
+	server.StartTLS()
+
+	conns = func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return newConns
+	}
+	http2Requests = func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return h2
+	}
+	return server, conns, http2Requests
+}
+
+// Downloads the server's file in parts, at most 4 of them at a time
+func downloadInParts(t testing.TB, server *httptest.Server, parts int, disableKeepAlives bool) {
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	d, err := NewFromConfig(&Config{
+		Url:               server.URL + "/book.pdf",
+		OutFilename:       outFilename,
+		Concurrency:       parts,
+		MinPartSize:       1,
+		MaxOpenParts:      4,
+		DisableKeepAlives: disableKeepAlives,
+		TLSConfig:         server.Client().Transport.(*http.Transport).TLSClientConfig,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnectionReuse(t *testing.T) {
+	server, conns, _ := newCountingServer(t, false)
+	defer server.Close()
+
+	// the queued parts reuse the connections of the finished ones. A part
+	// frees its slot before its connection is back in the idle pool, so
+	// the next part may dial now and then, but far less than once per part.
+	downloadInParts(t, server, 32, false)
+	if n := conns(); n > 16 {
+		t.Errorf("Expected the 32 parts to reuse the connections, got %d connections", n)
+	}
+
+	server2, conns2, _ := newCountingServer(t, false)
+	defer server2.Close()
+	downloadInParts(t, server2, 32, true)
+	if n := conns2(); n != 33 {
+		t.Errorf("Expected a connection per request without keep-alives, got %d", n)
+	}
+}
+
+func TestHTTP2(t *testing.T) {
+	server, conns, http2Requests := newCountingServer(t, true)
+	defer server.Close()
+
+	downloadInParts(t, server, 8, false)
+	if n := http2Requests(); n != 9 {
+		t.Errorf("Expected the HEAD and 8 parts over HTTP/2, got %d", n)
+	}
+	if n := conns(); n != 1 {
+		t.Errorf("Expected the parts to share an HTTP/2 connection, got %d connections", n)
+	}
+}
+
+// Many small parts over TLS, where setting up a connection costs
+// more than the transfer
+func BenchmarkConnectionReuse(b *testing.B) {
+	for _, test := range []struct {
+		name              string
+		disableKeepAlives bool
+	}{
+		{"keep-alive", false},
+		{"no-keep-alive", true},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			server, _, _ := newCountingServer(b, false)
+			defer server.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				downloadInParts(b, server, 64, test.disableKeepAlives)
+			}
+		})
+	}
+}
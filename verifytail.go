@@ -0,0 +1,96 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Downloads the last ResumeVerifyTail bytes of the downloaded bytes of a
+// part again and compares them to the ones on disk, since a write torn by
+// a crash may have left garbage at the end. Returns the number of bytes
+// to continue the part from, which is before the first mismatch. If the
+// bytes can't be fetched, the whole tail is downloaded again.
+func (d *Downloader) verifyTail(partNum int, part partRange, downloaded int) int {
+	tail := d.config.ResumeVerifyTail
+	if tail <= 0 || downloaded <= 0 {
+		return downloaded
+	}
+	if tail > downloaded {
+		tail = downloaded
+	}
+	safe := downloaded - tail
+
+	local := make([]byte, tail)
+	if err := d.readPartAt(partNum, part, local, safe); err != nil {
+		d.logf("Cannot read the tail of part %d: %v, downloading it again", partNum, err)
+		return d.truncatePart(partNum, safe)
+	}
+	remote, err := d.fetchRange(part.Start+safe, part.Start+downloaded-1)
+	if err != nil {
+		d.logf("Cannot verify the tail of part %d: %v, downloading it again", partNum, err)
+		return d.truncatePart(partNum, safe)
+	}
+
+	for i := range local {
+		if local[i] != remote[i] {
+			d.logf("Part %d is corrupt after %d bytes, continuing from there", partNum, safe+i)
+			return d.truncatePart(partNum, safe+i)
+		}
+	}
+	return downloaded
+}
+
+// Reads len(p) bytes of the part starting at its offset-th byte
+func (d *Downloader) readPartAt(partNum int, part partRange, p []byte, offset int) error {
+	if d.prealloc != nil {
+		_, err := d.prealloc.ReadAt(p, int64(part.Start+offset))
+		return err
+	}
+
+	_, err := d.readFileAt(d.getPartFilename(partNum), p, int64(offset))
+	return err
+}
+
+// Drops the bytes of the part after size and returns size. The
+// preallocated file is overwritten anyway, only part files are truncated.
+func (d *Downloader) truncatePart(partNum, size int) int {
+	if d.prealloc == nil {
+		if err := d.storage.Truncate(d.getPartFilename(partNum), int64(size)); err != nil {
+			d.logf("Cannot truncate part %d: %v", partNum, err)
+		}
+	}
+	return size
+}
+
+// Downloads the bytes [start, stop] of the file
+func (d *Downloader) fetchRange(start, stop int) ([]byte, error) {
+	ctx, cancel := d.requestContext(d.context)
+	defer cancel()
+	req, err := d.newRequest(ctx, http.MethodGet, d.ResolvedURL())
+	if err != nil {
+		return nil, err
+	}
+	offset := int(d.config.RangeStart)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset+start, offset+stop))
+	req.Header.Set("Accept-Encoding", "identity")
+	if d.ifRange != "" {
+		req.Header.Set("If-Range", d.ifRange)
+	}
+
+	res, err := d.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusPartialContent {
+		return nil, newStatusError(res)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := io.CopyN(buf, res.Body, int64(stop-start+1)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// DownloadTo downloads the file and writes it to w in order, without
+// creating the output file. Since w can't be seeked, resume is not
+// supported in this mode. When the server supports ranges, the parts
+// are buffered in temporary files and written to w as soon as all the
+// parts before them have been written.
+func (d *Downloader) DownloadTo(ctx context.Context, w io.Writer) error {
+	if d.config.Resume {
+		return errors.New("Resume is not supported when downloading to an io.Writer")
+	}
+
+	return d.run(ctx, func() error {
+		contentSize, err := d.probe()
+		if err != nil {
+			return d.pauseError(err)
+		}
+		if err := d.fetchChecksum(); err != nil {
+			return err
+		}
+		if contentSize > 0 {
+			return d.multiDownloadTo(w, contentSize)
+		}
+
+		return d.streamTo(w)
+	})
+}
+
+func (d *Downloader) multiDownloadTo(w io.Writer, contentSize int) error {
+	streamDir, err := ioutil.TempDir(d.config.TempDir, "go-dl")
+	if err != nil {
+		return err
+	}
+	d.streamDir = streamDir
+	defer func() {
+		os.RemoveAll(streamDir)
+		d.streamDir = ""
+	}()
+
+	d.ifRange = (&metadata{ETag: d.etag, LastModified: d.lastModified}).ifRange()
+	d.startProgress(int64(contentSize))
+
+	parts := splitRanges(contentSize, d.partsCount(contentSize))
+	partsDone, errCh := d.startParts(parts, 0)
+	// stop the remaining parts before their directory is removed
+	defer d.stopParts(partsDone)
+
+	sums := d.checksums()
+	writer := checksumWriter(w, sums)
+
+	for i, done := range partsDone {
+		done.Wait()
+
+		select {
+		case err := <-errCh:
+			return err
+		default:
+		}
+		if err := d.context.Err(); err != nil {
+			return err
+		}
+
+		expected := int64(parts[i].Stop - parts[i].Start + 1)
+		if err := d.copyPart(writer, d.getPartFilename(i+1), expected); err != nil {
+			return err
+		}
+	}
+
+	return verifyChecksums(sums)
+}
+
+// Writes the part file to w and removes it, the part must have expected bytes
+func (d *Downloader) copyPart(w io.Writer, filename string, expected int64) error {
+	source, err := d.storage.Open(filename)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(w, source)
+	source.Close()
+	d.storage.Remove(filename)
+	if err != nil {
+		return err
+	}
+	if written != expected {
+		return fmt.Errorf("Part %s has %d bytes, expected %d", filepath.Base(filename), written, expected)
+	}
+
+	return nil
+}
+
+// Whether path is an existing local file which isn't a regular file nor
+// a directory, e.g. a FIFO or /dev/stdout, which can't be seeked
+func (d *Downloader) isSpecialFile(path string) bool {
+	if !d.isLocalStorage() {
+		return false
+	}
+	fileInfo, err := os.Stat(path)
+	return err == nil && !fileInfo.Mode().IsRegular() && !fileInfo.IsDir()
+}
+
+// Downloads the file in a single stream straight into the output file,
+// which is a FIFO or a device. There are no part files to merge and
+// nothing to resume from, so Concurrency and Resume are ignored.
+func (d *Downloader) downloadToSpecialFile() error {
+	if d.config.Concurrency > 1 || d.config.Resume {
+		d.logf("%s is not a regular file, downloading in a single stream without resume", d.config.OutFilename)
+	}
+	if err := d.fetchChecksum(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(d.config.OutFilename, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.complete(d.streamTo(f))
+}
@@ -0,0 +1,50 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDownloadTo(t *testing.T) {
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	rangesServer := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer rangesServer.Close()
+
+	// doesn't advertise range support
+	simpleServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(original)
+	}))
+	defer simpleServer.Close()
+
+	for _, url := range []string{rangesServer.URL + "/book.pdf", simpleServer.URL + "/book.pdf"} {
+		d, err := NewFromConfig(&Config{
+			Url:         url,
+			Concurrency: 4,
+			OutFilename: tempOutFilename(t),
+		})
+		if err != nil {
+			t.Fatal("Coudn't initialize downloader")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		buffer := &bytes.Buffer{}
+		err = d.DownloadTo(ctx, buffer)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(original, buffer.Bytes()) {
+			t.Errorf("Downloaded content from %s is not the same as original file", url)
+		}
+	}
+}
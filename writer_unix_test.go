@@ -0,0 +1,78 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package downloader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDownloadToFIFO(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	original, err := ioutil.ReadFile("./files/book.pdf")
+	if err != nil {
+		t.Fatal("Cannot read ./files/book.pdf")
+	}
+
+	dir, err := ioutil.TempDir("", "go_dl_fifo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fifo := filepath.Join(dir, "out")
+	if err := syscall.Mkfifo(fifo, 0666); err != nil {
+		t.Skipf("Cannot create a FIFO: %v", err)
+	}
+
+	received := make(chan []byte, 1)
+	go func() {
+		f, err := os.Open(fifo)
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer f.Close()
+		data, _ := ioutil.ReadAll(f)
+		received <- data
+	}()
+
+	d, err := NewFromConfig(&Config{
+		Url:         server.URL + "/book.pdf",
+		Concurrency: 4,
+		MinPartSize: 1,
+		OutFilename: fifo,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if d.OutputPath() != fifo {
+		t.Errorf("Expected the FIFO not to be renamed, got %s", d.OutputPath())
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case data := <-received:
+		if !bytes.Equal(original, data) {
+			t.Error("Data read from the FIFO differs from the original")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the FIFO to be written")
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(matches) != 1 {
+		t.Errorf("Expected no part files next to the FIFO, got %v", matches)
+	}
+}
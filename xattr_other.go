@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package downloader
+
+// Extended attributes aren't supported on this platform
+func setXattr(path, name, value string) error {
+	return errXattrUnsupported
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package downloader
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// Sets the extended attribute name of the file at path
+func setXattr(path, name, value string) error {
+	err := unix.Setxattr(path, name, []byte(value), 0)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+		return errXattrUnsupported
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package downloader
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestWriteProvenanceXattr(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("./files/")))
+	defer server.Close()
+
+	outFilename := tempOutFilename(t)
+	defer os.Remove(outFilename)
+
+	url := server.URL + "/book.pdf"
+	logger := &recordingLogger{}
+	d, err := NewFromConfig(&Config{
+		Url:                  url,
+		OutFilename:          outFilename,
+		Logger:               logger,
+		WriteProvenanceXattr: true,
+	})
+	if err != nil {
+		t.Fatal("Coudn't initialize downloader")
+	}
+	if err := d.Download(); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 256)
+	n, err := unix.Getxattr(outFilename, xattrSourceURL, buf)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+		if !logger.contains("provenance isn't recorded") {
+			t.Error("Expected a note that the provenance isn't recorded")
+		}
+		t.Skip("The temp directory doesn't support extended attributes")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != url {
+		t.Errorf("Expected source url %s, got %s", url, got)
+	}
+
+	n, err = unix.Getxattr(outFilename, xattrDownloadedAt, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloadedAt, err := time.Parse(time.RFC3339, string(buf[:n]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(downloadedAt) > time.Minute {
+		t.Errorf("Expected a recent download time, got %v", downloadedAt)
+	}
+}